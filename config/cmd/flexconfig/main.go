@@ -0,0 +1,78 @@
+// Command flexconfig prints the effective configuration of a config file
+// and where every value came from, using the same layering, .env and
+// environment rules as config.SuperAgent.LoadFile. Values read with a
+// ${file:/path} variable are redacted like secret fields.
+//
+//	flexconfig -dir etc -env production -prefix APP app.yaml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/20326/flexbox/config"
+)
+
+func main() {
+	var (
+		dir         = flag.String("dir", ".", "directory of the config files")
+		env         = flag.String("env", "", "environment, defaults to GOLANG_ENV, ENV or local")
+		prefix      = flag.String("prefix", "", "prefix of environment variable overrides, e.g. APP")
+		sliceAppend = flag.Bool("append", false, "append slices of later layers instead of replacing them")
+		redact      = flag.String("redact", "password,secret,token,key", "comma separated key names whose values are redacted")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] file\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	s := config.New(*dir).SetSliceAppend(*sliceAppend)
+	if *env != "" {
+		s.Env = *env
+	}
+	if *prefix != "" {
+		s.SetEnvPrefix(*prefix)
+	}
+
+	var object map[string]interface{}
+	errs := s.LoadFile(flag.Arg(0), &object).End()
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, setting := range s.Explain() {
+		value := setting.Value
+		if setting.Secret || redacted(setting.Key, *redact) {
+			value = config.Redacted
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, value, setting.Source)
+	}
+	_ = w.Flush()
+
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+}
+
+// redacted reports whether the last segment of key contains one of the
+// comma separated names.
+func redacted(key, names string) bool {
+	last := strings.ToLower(key[strings.LastIndex(key, ".")+1:])
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" && strings.Contains(last, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,6 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -11,7 +10,6 @@ import (
 	"sync"
 
 	"github.com/joho/godotenv"
-	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -31,6 +29,16 @@ type SuperAgent struct {
 	Path   string
 	Errors []error
 	logger *log.Logger
+
+	// EnvOverlay overrides loaded struct fields from environment variables
+	// after decoding, see SetEnvPrefix.
+	EnvOverlay bool
+	EnvPrefix  string
+
+	// SliceAppend appends slices of later layers instead of replacing them.
+	SliceAppend bool
+
+	loaded []loaded
 }
 
 var (
@@ -73,6 +81,28 @@ func (s *SuperAgent) SetPath(dir string) *SuperAgent {
 	return s
 }
 
+// SetEnvPrefix enables the environment overlay. Fields tagged with
+// `env:"NAME"` are always read from NAME, untagged fields are read from
+// PREFIX_SECTION_FIELD (e.g. APP_LOGGER_LEVEL) when prefix is not empty.
+func (s *SuperAgent) SetEnvPrefix(prefix string) *SuperAgent {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.EnvOverlay = true
+	s.EnvPrefix = prefix
+	return s
+}
+
+// SetSliceAppend controls how layered files merge slices, they are replaced
+// by default and appended when enable is true.
+func (s *SuperAgent) SetSliceAppend(enable bool) *SuperAgent {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.SliceAppend = enable
+	return s
+}
+
 func (s *SuperAgent) SetLogger(logger *log.Logger) *SuperAgent {
 	s.m.Lock()
 	defer s.m.Unlock()
@@ -81,6 +111,22 @@ func (s *SuperAgent) SetLogger(logger *log.Logger) *SuperAgent {
 	return s
 }
 
+// clone returns a SuperAgent with the same settings and no errors.
+func (s *SuperAgent) clone() *SuperAgent {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return &SuperAgent{
+		Debug:       s.Debug,
+		Env:         s.Env,
+		Path:        s.Path,
+		logger:      s.logger,
+		EnvOverlay:  s.EnvOverlay,
+		EnvPrefix:   s.EnvPrefix,
+		SliceAppend: s.SliceAppend,
+	}
+}
+
 func (s *SuperAgent) addError(err error) *SuperAgent {
 	if err != nil {
 		if s.Errors == nil {
@@ -98,46 +144,90 @@ func (s *SuperAgent) LoadFile(name string, object interface{}) *SuperAgent {
 
 	// fixme: https://stackoverflow.com/questions/23847003/golang-tests-and-working-directory
 
-	var filename string
-	ext := filepath.Ext(name)
-	envName := strings.Join([]string{strings.TrimRight(name, ext), ".", s.Env, ext}, "")
+	files, err := s.resolve(name)
+	if err != nil {
+		return s.addError(err)
+	}
 
-	for _, val := range []string{envName, name} {
-		filename = path.Join(s.Path, val)
-		if _, err := os.Stat(filename); !os.IsNotExist(err) {
-			break
+	layers := make([][]byte, 0, len(files))
+	for _, filename := range files {
+		log.Printf("ReadFile %s\n", filename)
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return s.addError(err)
 		}
+		layers = append(layers, data)
 	}
 
-	log.Printf("ReadFile %s\n", filename)
-	data, err := os.ReadFile(filename)
-
-	return s.addError(err).load(data, ext, object)
+	return s.load(layers, files, filepath.Ext(name), object)
 }
 
 func (s *SuperAgent) LoadData(data []byte, ext string, object interface{}) *SuperAgent {
 	s.m.Lock()
 	defer s.m.Unlock()
 
-	return s.load(data, ext, object)
+	return s.load([][]byte{data}, []string{"data"}, ext, object)
 }
 
 func (s *SuperAgent) End() []error {
 	return s.Errors
 }
 
-func (s *SuperAgent) load(data []byte, ext string, object interface{}) *SuperAgent {
-	var err error
-	switch ext {
-	case ".json":
-		err = json.Unmarshal(data, object)
-	case ".yaml", ".yml":
-		err = yaml.Unmarshal(data, object)
-	default:
-		err = fmt.Errorf("format of %s not supportted", ext)
+// resolve returns the existing layers of name in merge order:
+// name.ext, name.${env}.ext and name.local.ext.
+func (s *SuperAgent) resolve(name string) ([]string, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	var files []string
+	for _, val := range []string{name, base + "." + s.Env + ext, base + ".local" + ext} {
+		filename := path.Join(s.Path, val)
+		if len(files) > 0 && files[len(files)-1] == filename {
+			continue
+		}
+		if _, err := os.Stat(filename); err == nil {
+			files = append(files, filename)
+		}
+	}
+
+	if len(files) == 0 {
+		_, err := os.Stat(path.Join(s.Path, name))
+		return nil, err
+	}
+	return files, nil
+}
+
+// load decodes the layers into object, sources name the layers for Explain.
+func (s *SuperAgent) load(layers [][]byte, sources []string, ext string, object interface{}) *SuperAgent {
+	o, files := origins{}, secrets{}
+	s.loaded = append(s.loaded, loaded{object: object, origins: o, secrets: files})
+
+	for _, err := range applyDefaults(object, o) {
+		s.addError(err)
 	}
+
+	for i, data := range layers {
+		for key, source := range fileOrigins(sources[i], data, ext) {
+			o[key] = source
+		}
+	}
+
+	err := s.decode(layers, ext, object, files)
 	// log.Printf("Load object: %v\n", object)
-	return s.addError(err)
+	if err != nil {
+		return s.addError(err)
+	}
+
+	if s.EnvOverlay {
+		for _, err := range applyEnv(object, s.EnvPrefix, o) {
+			s.addError(err)
+		}
+	}
+
+	for _, err := range validate(object) {
+		s.addError(err)
+	}
+	return s
 }
 
 func SelfDir() string {
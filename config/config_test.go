@@ -1,8 +1,13 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // go test -c && ./config.test
@@ -64,3 +69,736 @@ func TestLoadData(t *testing.T) {
 		t.Errorf("Expected %v, but got %v", expected, actual)
 	}
 }
+
+func TestLoadDataEnvOverlay(t *testing.T) {
+	type logger struct {
+		Level    string        `yaml:"level"`
+		MaxSize  int64         `yaml:"maxSize"`
+		Compress bool          `yaml:"compress"`
+		Rotate   time.Duration `yaml:"rotate"`
+	}
+	type conf struct {
+		Addrs  []string `yaml:"addrs" env:"REDIS_ADDR"`
+		Logger logger   `yaml:"logger"`
+		Secret string   `yaml:"secret" env:"-"`
+	}
+
+	t.Setenv("REDIS_ADDR", "10.0.0.1:6379, 10.0.0.2:6379")
+	t.Setenv("APP_LOGGER_LEVEL", "warn")
+	t.Setenv("APP_LOGGER_MAX_SIZE", "1024")
+	t.Setenv("APP_LOGGER_COMPRESS", "true")
+	t.Setenv("APP_LOGGER_ROTATE", "1h")
+	t.Setenv("APP_SECRET", "leaked")
+
+	data := "addrs: [127.0.0.1:6379]\nlogger:\n  level: debug\n  maxSize: 10\nsecret: foo\n"
+	expected := conf{
+		Addrs:  []string{"10.0.0.1:6379", "10.0.0.2:6379"},
+		Logger: logger{Level: "warn", MaxSize: 1024, Compress: true, Rotate: time.Hour},
+		Secret: "foo",
+	}
+
+	var actual conf
+	errs := New("testdata").SetEnvPrefix("APP").LoadData([]byte(data), ".yaml", &actual).End()
+
+	if len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestLoadDataEnvOverlayInvalid(t *testing.T) {
+	type conf struct {
+		Port int `json:"port"`
+	}
+
+	t.Setenv("APP_PORT", "http")
+
+	var actual conf
+	errs := New("testdata").SetEnvPrefix("APP_").LoadData([]byte(`{"port": 80}`), ".json", &actual).End()
+
+	if len(errs) != 1 {
+		t.Errorf("Expected one error, but got %v", errs)
+	}
+}
+
+func TestLoadTomlFile(t *testing.T) {
+	s := New("testdata")
+
+	expected := map[string]interface{}{
+		"foo": "bar",
+	}
+
+	var actual map[string]interface{}
+	errs := s.LoadFile("test.toml", &actual).End()
+
+	if len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestLoadIniFile(t *testing.T) {
+	type server struct {
+		Port int `ini:"port"`
+	}
+	type conf struct {
+		Foo    string `ini:"foo"`
+		Server server `ini:"server"`
+	}
+
+	s := New("testdata")
+
+	expected := conf{Foo: "bar", Server: server{Port: 8080}}
+
+	var actual conf
+	errs := s.LoadFile("test.ini", &actual).End()
+
+	if len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	RegisterFormat("KV", func(data []byte, v interface{}) error {
+		parts := strings.SplitN(string(data), "=", 2)
+		*(v.(*map[string]interface{})) = map[string]interface{}{parts[0]: parts[1]}
+		return nil
+	})
+
+	expected := map[string]interface{}{
+		"foo": "bar",
+	}
+
+	var actual map[string]interface{}
+	errs := New("testdata").LoadData([]byte("foo=bar"), ".kv", &actual).End()
+
+	if len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, but got %v", expected, actual)
+	}
+
+	errs = New("testdata").LoadData([]byte("foo=bar"), ".unknown", &actual).End()
+	if len(errs) != 1 {
+		t.Errorf("Expected one error, but got %v", errs)
+	}
+}
+
+func TestLoadFileLayered(t *testing.T) {
+	s := New("testdata")
+	s.Env = "production"
+
+	expected := map[string]interface{}{
+		"foo": "pai",
+	}
+
+	var actual map[string]interface{}
+	errs := s.LoadFile("test.yaml", &actual).End()
+
+	if len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestLoadFileDeepMerge(t *testing.T) {
+	type logger struct {
+		Level   string `yaml:"level"`
+		MaxSize int64  `yaml:"maxSize"`
+	}
+	type conf struct {
+		Name   string                 `yaml:"name"`
+		Logger logger                 `yaml:"logger"`
+		Redis  map[string]interface{} `yaml:"redis"`
+	}
+
+	tests := []struct {
+		append bool
+		addrs  []interface{}
+	}{
+		{false, []interface{}{"10.0.0.1:6379"}},
+		{true, []interface{}{"127.0.0.1:6379", "10.0.0.1:6379"}},
+	}
+
+	for _, tt := range tests {
+		s := New("testdata").SetSliceAppend(tt.append)
+		s.Env = "production"
+
+		expected := conf{
+			Name:   "app",
+			Logger: logger{Level: "warn", MaxSize: 100},
+			Redis:  map[string]interface{}{"addrs": tt.addrs, "database": 2},
+		}
+
+		var actual conf
+		errs := s.LoadFile("app.yaml", &actual).End()
+
+		if len(errs) > 0 {
+			t.Errorf("Unexpected errors: %v", errs)
+		}
+
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Expected %v, but got %v", expected, actual)
+		}
+	}
+}
+
+func TestLoadFileDeepMergeHCL(t *testing.T) {
+	type logger struct {
+		Level   string `hcl:"level"`
+		MaxSize int64  `hcl:"maxSize"`
+	}
+	type redis struct {
+		Addrs       []string      `hcl:"addrs"`
+		Database    int           `hcl:"database"`
+		DialTimeout time.Duration `hcl:"dialTimeout"`
+	}
+	type conf struct {
+		Name   string `hcl:"name"`
+		Logger logger `hcl:"logger"`
+		Redis  redis  `hcl:"redis"`
+	}
+
+	tests := []struct {
+		append bool
+		addrs  []string
+	}{
+		{false, []string{"10.0.0.1:6379"}},
+		{true, []string{"127.0.0.1:6379", "10.0.0.1:6379"}},
+	}
+
+	for _, tt := range tests {
+		s := New("testdata").SetSliceAppend(tt.append)
+		s.Env = "production"
+
+		expected := conf{
+			Name:   "app",
+			Logger: logger{Level: "warn", MaxSize: 100},
+			Redis:  redis{Addrs: tt.addrs, Database: 2, DialTimeout: 5 * time.Second},
+		}
+
+		var actual conf
+		errs := s.LoadFile("app.hcl", &actual).End()
+
+		if len(errs) > 0 {
+			t.Errorf("Unexpected errors: %v", errs)
+		}
+
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Expected %v, but got %v", expected, actual)
+		}
+	}
+}
+
+func TestLoadFileDeepMergeINI(t *testing.T) {
+	type logger struct {
+		Level   string `ini:"level"`
+		MaxSize int64  `ini:"maxSize"`
+	}
+	type conf struct {
+		Name   string `ini:"name"`
+		Logger logger `ini:"logger"`
+	}
+
+	s := New("testdata")
+	s.Env = "production"
+
+	expected := conf{Name: "app", Logger: logger{Level: "warn", MaxSize: 100}}
+
+	var actual conf
+	errs := s.LoadFile("app.ini", &actual).End()
+
+	if len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, but got %v", expected, actual)
+	}
+
+	// INI has no lists to append
+	s = New("testdata").SetSliceAppend(true)
+	s.Env = "production"
+	errs = s.LoadFile("app.ini", &actual).End()
+	if len(errs) != 1 {
+		t.Errorf("Expected one error, but got %v", errs)
+	}
+}
+
+func TestMergeMap(t *testing.T) {
+	dst := map[string]interface{}{
+		"a": map[string]interface{}{"b": 1, "c": []interface{}{1}},
+		"d": "x",
+	}
+	src := map[string]interface{}{
+		"a": map[string]interface{}{"c": []interface{}{2}},
+		"d": map[string]interface{}{"e": true},
+	}
+
+	expected := map[string]interface{}{
+		"a": map[string]interface{}{"b": 1, "c": []interface{}{1, 2}},
+		"d": map[string]interface{}{"e": true},
+	}
+
+	actual := mergeMap(dst, src, true)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	type conf struct {
+		Level string `yaml:"level"`
+	}
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.yaml")
+	if err := os.WriteFile(filename, []byte("level: debug\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan [2]string, 1)
+	var actual conf
+	w, err := New(dir).Watch("app.yaml", &actual, func(old, new interface{}) {
+		changes <- [2]string{old.(*conf).Level, new.(*conf).Level}
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer w.Close()
+
+	if actual.Level != "debug" {
+		t.Errorf("Expected debug, but got %v", actual.Level)
+	}
+
+	// a broken file keeps the last good value
+	if err := os.WriteFile(filename, []byte("level: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w.Err() == nil {
+		t.Fatal("Expected a reload error")
+	}
+	if level := w.Load().(*conf).Level; level != "debug" {
+		t.Errorf("Expected debug, but got %v", level)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(filename, []byte("level: warn\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case change := <-changes:
+		if change != [2]string{"debug", "warn"} {
+			t.Errorf("Expected [debug warn], but got %v", change)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a change callback")
+	}
+
+	if w.Err() != nil {
+		t.Errorf("Unexpected error: %v", w.Err())
+	}
+	if level := w.Load().(*conf).Level; level != "warn" {
+		t.Errorf("Expected warn, but got %v", level)
+	}
+	// reloads do not write to the object passed to Watch
+	if actual.Level != "debug" {
+		t.Errorf("Expected debug, but got %v", actual.Level)
+	}
+}
+
+func TestWatchConcurrentLoad(t *testing.T) {
+	type conf struct {
+		Level string `yaml:"level"`
+	}
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.yaml")
+	if err := os.WriteFile(filename, []byte("level: debug\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan struct{}, 10)
+	var actual conf
+	w, err := New(dir).Watch("app.yaml", &actual, func(old, new interface{}) {
+		changes <- struct{}{}
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer w.Close()
+
+	// run with -race, readers and reloads share nothing but Load
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					_ = w.Load().(*conf).Level
+					_ = actual.Level
+				}
+			}
+		}()
+	}
+
+	if err := os.WriteFile(filename, []byte("level: warn\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected a change callback")
+	}
+	close(done)
+	wg.Wait()
+
+	if level := w.Load().(*conf).Level; level != "warn" {
+		t.Errorf("Expected warn, but got %v", level)
+	}
+}
+
+func TestLoadDataValidate(t *testing.T) {
+	type logger struct {
+		Level   string        `yaml:"level" validate:"required,oneof=debug info warn"`
+		MaxSize int64         `yaml:"maxSize" validate:"min=1"`
+		Rotate  time.Duration `yaml:"rotate" validate:"max=24h"`
+	}
+	type conf struct {
+		Port   int      `yaml:"port" validate:"min=1,max=65535"`
+		Addrs  []string `yaml:"addrs" validate:"min=1"`
+		Logger logger   `yaml:"logger"`
+	}
+
+	data := "port: 70000\naddrs: []\nlogger:\n  level: trace\n  maxSize: 0\n  rotate: 48h\n"
+	expected := []string{
+		"port: must be at most 65535",
+		"addrs: must be at least 1",
+		"logger.level: must be one of [debug info warn]",
+		"logger.maxSize: must be at least 1",
+		"logger.rotate: must be at most 24h",
+	}
+
+	var actual conf
+	errs := New("testdata").LoadData([]byte(data), ".yaml", &actual).End()
+
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	if !reflect.DeepEqual(expected, messages) {
+		t.Errorf("Expected %v, but got %v", expected, messages)
+	}
+
+	var valid conf
+	data = "port: 80\naddrs: [127.0.0.1]\nlogger:\n  level: info\n  maxSize: 10\n"
+	if errs := New("testdata").LoadData([]byte(data), ".yaml", &valid).End(); len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+}
+
+func TestLoadDataDefaults(t *testing.T) {
+	type redis struct {
+		Addrs       []string      `yaml:"addrs" default:"127.0.0.1:6379"`
+		DialTimeout time.Duration `yaml:"dialTimeout" default:"10s"`
+		PoolFIFO    bool          `yaml:"poolFIFO" default:"true"`
+	}
+	type conf struct {
+		Name  string `yaml:"name" default:"app"`
+		Port  int    `yaml:"port" default:"8080"`
+		Redis redis  `yaml:"redis"`
+	}
+
+	data := "port: 80\nredis:\n  poolFIFO: false\n"
+	expected := conf{
+		Name: "app",
+		Port: 80,
+		Redis: redis{
+			Addrs:       []string{"127.0.0.1:6379"},
+			DialTimeout: 10 * time.Second,
+			PoolFIFO:    false,
+		},
+	}
+
+	var actual conf
+	errs := New("testdata").LoadData([]byte(data), ".yaml", &actual).End()
+
+	if len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestLoadDataDurationAndSize(t *testing.T) {
+	type conf struct {
+		ReadTimeout time.Duration            `json:"readTimeout" yaml:"readTimeout"`
+		Backoff     []time.Duration          `json:"backoff" yaml:"backoff"`
+		Timeouts    map[string]time.Duration `json:"timeouts" yaml:"timeouts"`
+		MaxSize     Size                     `json:"maxSize" yaml:"maxSize"`
+		Buffer      Size                     `json:"buffer" yaml:"buffer"`
+		ID          int64                    `json:"id" yaml:"id"`
+	}
+
+	expected := conf{
+		ReadTimeout: 3 * time.Second,
+		Backoff:     []time.Duration{500 * time.Millisecond, 2},
+		Timeouts:    map[string]time.Duration{"dial": time.Minute},
+		MaxSize:     1 << 30,
+		Buffer:      512,
+		ID:          9007199254740993,
+	}
+
+	tests := map[string]string{
+		".json": `{"readTimeout": "3s", "backoff": ["500ms", 2], "timeouts": {"dial": "1m"}, "maxSize": "1GiB", "buffer": 512, "id": 9007199254740993}`,
+		".yaml": "readTimeout: 3s\nbackoff: [500ms, 2]\ntimeouts:\n  dial: 1m\nmaxSize: 1GiB\nbuffer: 512\nid: 9007199254740993\n",
+	}
+
+	for ext, data := range tests {
+		var actual conf
+		errs := New("testdata").LoadData([]byte(data), ext, &actual).End()
+
+		if len(errs) > 0 {
+			t.Errorf("%s: Unexpected errors: %v", ext, errs)
+		}
+
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s: Expected %v, but got %v", ext, expected, actual)
+		}
+	}
+
+	var actual conf
+	errs := New("testdata").LoadData([]byte(`{"readTimeout": "3x"}`), ".json", &actual).End()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `readTimeout: invalid duration "3x"`) {
+		t.Errorf("Expected an invalid duration error, but got %v", errs)
+	}
+
+	errs = New("testdata").LoadData([]byte("maxSize: 12XB\n"), ".yaml", &actual).End()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `unknown unit "XB"`) {
+		t.Errorf("Expected an unknown unit error, but got %v", errs)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]Size{
+		"1024":   1024,
+		"512MB":  512 * 1000 * 1000,
+		"1GiB":   1 << 30,
+		"1.5KiB": 1536,
+		"10 kb":  10000,
+	}
+
+	for raw, expected := range tests {
+		actual, err := ParseSize(raw)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", raw, err)
+		}
+		if actual != expected {
+			t.Errorf("%s: Expected %v, but got %v", raw, expected, actual)
+		}
+	}
+
+	for _, raw := range []string{"", "GB", "1XB", "1.2.3MB"} {
+		if _, err := ParseSize(raw); err == nil {
+			t.Errorf("%s: Expected an error", raw)
+		}
+	}
+}
+
+func TestLoadDataInterpolate(t *testing.T) {
+	type conf struct {
+		Addr     string                 `yaml:"addr"`
+		Port     int                    `yaml:"port"`
+		Password string                 `yaml:"password"`
+		Literal  string                 `yaml:"literal"`
+		Extra    map[string]interface{} `yaml:"extra"`
+	}
+
+	secret := filepath.Join(t.TempDir(), "db_pass")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("REDIS_HOST", "10.0.0.1")
+	t.Setenv("REDIS_PORT", "6380")
+	t.Setenv("REDIS_PASSWORD", "file://"+secret)
+
+	// file:// values are not secret references, only ${file:/path} is
+	data := "addr: ${REDIS_HOST}:${REDIS_DB:-0}\nport: ${REDIS_PORT}\npassword: ${REDIS_PASSWORD}\nliteral: $${HOME}\nextra:\n  token: ${file:" + secret + "}\n  dsn: file:///data.db?mode=ro\n"
+	expected := conf{
+		Addr:     "10.0.0.1:0",
+		Port:     6380,
+		Password: "file://" + secret,
+		Literal:  "${HOME}",
+		Extra:    map[string]interface{}{"token": "s3cret", "dsn": "file:///data.db?mode=ro"},
+	}
+
+	var actual conf
+	errs := New("testdata").LoadData([]byte(data), ".yaml", &actual).End()
+
+	if len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, but got %v", expected, actual)
+	}
+
+	data = "addr: ${MISSING_HOST}\npassword: ${MISSING_PASSWORD:?set the redis password}\n"
+	expectedErrs := []string{
+		"unresolved variable MISSING_HOST",
+		"variable MISSING_PASSWORD: set the redis password",
+	}
+
+	errs = New("testdata").LoadData([]byte(data), ".yaml", &actual).End()
+
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	if !reflect.DeepEqual(expectedErrs, messages) {
+		t.Errorf("Expected %v, but got %v", expectedErrs, messages)
+	}
+}
+
+func TestLoadDataInterpolateValues(t *testing.T) {
+	type conf struct {
+		Password string `yaml:"password" json:"password" toml:"password"`
+		Reset    bool   `yaml:"reset" json:"reset" toml:"reset"`
+	}
+
+	// A value cannot add keys or break the syntax of the file
+	t.Setenv("PW", "abc\nreset: true")
+	var actual conf
+	errs := New("testdata").LoadData([]byte("password: ${PW}\n"), ".yaml", &actual).End()
+	expected := conf{Password: "abc\nreset: true"}
+	if len(errs) > 0 || !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, but got %v %v", expected, actual, errs)
+	}
+
+	t.Setenv("PW", `a"b`)
+	actual = conf{}
+	errs = New("testdata").LoadData([]byte(`{"password": "${PW}"}`), ".json", &actual).End()
+	expected = conf{Password: `a"b`}
+	if len(errs) > 0 || !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, but got %v %v", expected, actual, errs)
+	}
+
+	// Comments are not expanded
+	for ext, data := range map[string]string{
+		".yaml": "# password: ${UNSET_PASSWORD}\npassword: doe\n",
+		".toml": "# password = \"${UNSET_PASSWORD}\"\npassword = \"doe\"\n",
+	} {
+		actual = conf{}
+		errs = New("testdata").LoadData([]byte(data), ext, &actual).End()
+		expected = conf{Password: "doe"}
+		if len(errs) > 0 || !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s: Expected %v, but got %v %v", ext, expected, actual, errs)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	type redis struct {
+		Addrs    []string `yaml:"addrs"`
+		Database int      `yaml:"database"`
+		Password string   `yaml:"password" secret:"true"`
+		PoolSize int      `yaml:"poolSize" default:"10"`
+	}
+	type conf struct {
+		Name   string `yaml:"name"`
+		Logger struct {
+			Level   string `yaml:"level"`
+			MaxSize int64  `yaml:"maxSize"`
+		} `yaml:"logger"`
+		Redis redis `yaml:"redis"`
+	}
+
+	t.Setenv("APP_REDIS_PASSWORD", "s3cret")
+
+	s := New("testdata").SetEnvPrefix("APP")
+	s.Env = "production"
+
+	var actual conf
+	if errs := s.LoadFile("app.yaml", &actual).End(); len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	expected := []Setting{
+		{Key: "name", Value: "app", Source: "app.yaml:1"},
+		{Key: "logger.level", Value: "warn", Source: "app.production.yaml:2"},
+		{Key: "logger.maxSize", Value: "100", Source: "app.yaml:4"},
+		{Key: "redis.addrs", Value: `["10.0.0.1:6379"]`, Source: "app.production.yaml:4"},
+		{Key: "redis.database", Value: "2", Source: "app.local.yaml:2"},
+		{Key: "redis.password", Value: Redacted, Source: "env:APP_REDIS_PASSWORD", Secret: true},
+		{Key: "redis.poolSize", Value: "10", Source: "default"},
+	}
+
+	if settings := s.Explain(); !reflect.DeepEqual(expected, settings) {
+		t.Errorf("Expected %v, but got %v", expected, settings)
+	}
+
+	s = New("testdata")
+	var data map[string]interface{}
+	s.LoadData([]byte("{\n  \"foo\": {\n    \"bar\": 1\n  }\n}"), ".json", &data)
+
+	expected = []Setting{{Key: "foo.bar", Value: "1", Source: "data:3"}}
+	if settings := s.Explain(); !reflect.DeepEqual(expected, settings) {
+		t.Errorf("Expected %v, but got %v", expected, settings)
+	}
+}
+
+func TestExplainFileSecret(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "db_pass")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values read from files are redacted without a secret tag, like in
+	// the map flexconfig decodes into
+	s := New("testdata")
+	var data map[string]interface{}
+	yml := "db:\n  host: localhost\n  password: ${file:" + secret + "}\ntokens:\n  - ${file:" + secret + "}\n"
+	if errs := s.LoadData([]byte(yml), ".yaml", &data).End(); len(errs) > 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	expected := []Setting{
+		{Key: "db.host", Value: "localhost", Source: "data:2"},
+		{Key: "db.password", Value: Redacted, Source: "data:3", Secret: true},
+		{Key: "tokens", Value: Redacted, Source: "data:4", Secret: true},
+	}
+	if settings := s.Explain(); !reflect.DeepEqual(expected, settings) {
+		t.Errorf("Expected %v, but got %v", expected, settings)
+	}
+}
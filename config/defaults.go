@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/20326/flexbox/pkg/defaults"
+)
+
+// applyDefaults sets zero fields of object from their `default` tag before
+// decoding, so values present in the config files still win, see
+// defaults.Apply. The tag is parsed like an environment variable:
+// durations use time.ParseDuration and slices are comma separated. Nested
+// structs and non-nil pointers to structs are filled recursively.
+func applyDefaults(object interface{}, o origins) []error {
+	var errs []error
+	defaults.Apply(object, func(path []reflect.StructField, err error) {
+		var name string
+		for _, field := range path {
+			if !field.Anonymous {
+				name = joinPath(name, fieldName(field))
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("default %s: %v", name, err))
+			return
+		}
+		o.set(name, "default")
+	})
+	return errs
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// hasDuration reports whether t contains a time.Duration anywhere.
+func hasDuration(t reflect.Type) bool {
+	return typeHasDuration(t, map[reflect.Type]bool{})
+}
+
+func typeHasDuration(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if t == durationType {
+		return true
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return typeHasDuration(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath == "" && typeHasDuration(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeDurations walks the generic tree along t and replaces strings
+// like "3s" or "500ms" and plain numbers (nanoseconds) at time.Duration
+// positions with time.Duration values, which every encoder writes in the
+// form its decoder expects.
+func normalizeDurations(tree interface{}, t reflect.Type, path string) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if tree == nil {
+		return nil, nil
+	}
+
+	if t == durationType {
+		d, err := toDuration(tree)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return d, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := tree.(map[string]interface{})
+		if !ok {
+			return tree, nil
+		}
+		for key, value := range m {
+			field, ok := lookupField(t, key)
+			if !ok {
+				continue
+			}
+			v, err := normalizeDurations(value, field.Type, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+	case reflect.Map:
+		m, ok := tree.(map[string]interface{})
+		if !ok {
+			return tree, nil
+		}
+		for key, value := range m {
+			v, err := normalizeDurations(value, t.Elem(), joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+	case reflect.Slice, reflect.Array:
+		s, ok := tree.([]interface{})
+		if !ok {
+			return tree, nil
+		}
+		for i, value := range s {
+			v, err := normalizeDurations(value, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			s[i] = v
+		}
+	}
+	return tree, nil
+}
+
+func toDuration(v interface{}) (time.Duration, error) {
+	switch v := v.(type) {
+	case string:
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %v", v, err)
+		}
+		return d, nil
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", v)
+		}
+		return time.Duration(n), nil
+	case int:
+		return time.Duration(v), nil
+	case int64:
+		return time.Duration(v), nil
+	case uint64:
+		return time.Duration(v), nil
+	case float64:
+		return time.Duration(v), nil
+	case time.Duration:
+		return v, nil
+	}
+	return 0, fmt.Errorf("invalid duration %v", v)
+}
+
+// lookupField finds the struct field a config key decodes into, by tag or
+// case-insensitive field name. Embedded structs are searched as well.
+func lookupField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Name
+		for _, tagKey := range []string{"yaml", "json", "toml", "hcl"} {
+			if tag, ok := field.Tag.Lookup(tagKey); ok {
+				if n := strings.Split(tag, ",")[0]; n != "" {
+					name = n
+					break
+				}
+			}
+		}
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && ft.Kind() == reflect.Struct {
+			if f, ok := lookupField(ft, key); ok {
+				return f, true
+			}
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/20326/flexbox/pkg/defaults"
+)
+
+// applyEnv overrides the fields of object with values from the environment.
+//
+// A field tagged with `env:"NAME"` is read from NAME. When prefix is not
+// empty, untagged fields are read from PREFIX_PARENT_FIELD, where every
+// segment is the upper snake case form of the yaml/json key, for example
+// `APP_LOGGER_MAX_SIZE`. Fields tagged with `env:"-"` are skipped.
+//
+// Generic maps have no tags, only their existing keys are overridden, as
+// strings, and only when prefix is not empty.
+func applyEnv(object interface{}, prefix string, o origins) []error {
+	v := reflect.ValueOf(object)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil
+	}
+	v = v.Elem()
+
+	prefix = strings.TrimRight(strings.ToUpper(prefix), "_")
+	switch m := v.Interface().(type) {
+	case map[string]interface{}:
+		if prefix != "" {
+			envMap(m, prefix, "", o)
+		}
+		return nil
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	return envStruct(v, prefix, "", o)
+}
+
+func envMap(m map[string]interface{}, prefix, path string, o origins) {
+	for key, value := range m {
+		name := prefix + "_" + envName(key)
+		if sub, ok := value.(map[string]interface{}); ok {
+			envMap(sub, name, joinPath(path, key), o)
+			continue
+		}
+		if raw, ok := os.LookupEnv(name); ok {
+			m[key] = raw
+			o.set(joinPath(path, key), "env:"+name)
+		}
+	}
+}
+
+func envStruct(v reflect.Value, prefix, path string, o origins) []error {
+	var errs []error
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fv := v.Field(i)
+		tag, tagged := field.Tag.Lookup("env")
+		if tag == "-" {
+			continue
+		}
+
+		fieldPath := path
+		if !field.Anonymous {
+			fieldPath = joinPath(path, fieldName(field))
+		}
+
+		name := tag
+		if !tagged {
+			if field.Anonymous {
+				name = prefix
+			} else if prefix != "" {
+				name = prefix + "_" + envName(fieldName(field))
+			}
+		}
+
+		if defaults.Nested(fv) {
+			errs = append(errs, envNested(fv, name, fieldPath, o)...)
+			continue
+		}
+
+		if name == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := defaults.SetValue(fv, raw); err != nil {
+			errs = append(errs, fmt.Errorf("env %s: %v", name, err))
+			continue
+		}
+		o.set(fieldPath, "env:"+name)
+	}
+	return errs
+}
+
+// envNested descends into a struct or pointer to struct field. Nil pointers
+// are only allocated when at least one of their fields is set.
+func envNested(fv reflect.Value, prefix, path string, o origins) []error {
+	if fv.Kind() != reflect.Ptr {
+		return envStruct(fv, prefix, path, o)
+	}
+	if !fv.IsNil() {
+		return envStruct(fv.Elem(), prefix, path, o)
+	}
+
+	tmp := reflect.New(fv.Type().Elem())
+	zero := reflect.Zero(fv.Type().Elem()).Interface()
+	errs := envStruct(tmp.Elem(), prefix, path, o)
+	if !reflect.DeepEqual(tmp.Elem().Interface(), zero) {
+		fv.Set(tmp)
+	}
+	return errs
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces the value of secret settings in Explain.
+const Redacted = "******"
+
+// A Setting is an effective config value and the place it came from.
+type Setting struct {
+	// Key is the dotted path of the value, e.g. `logger.level`.
+	Key   string
+	Value string
+	// Source is `file:line` (or `file` for formats without positions),
+	// `env:NAME`, `default` or `data` for LoadData. It is empty for values
+	// that were not loaded.
+	Source string
+	// Secret is set for fields tagged with `secret:"true"` and for values
+	// read with a `${file:/path}` variable, Value is redacted then.
+	Secret bool
+}
+
+func (s Setting) String() string {
+	if s.Source == "" {
+		return fmt.Sprintf("%s = %s", s.Key, s.Value)
+	}
+	return fmt.Sprintf("%s = %s (%s)", s.Key, s.Value, s.Source)
+}
+
+// origins maps lower case dotted keys to their source.
+type origins map[string]string
+
+func (o origins) set(key, source string) {
+	if o != nil {
+		o[strings.ToLower(key)] = source
+	}
+}
+
+// secrets is the set of lower case dotted keys whose value was read from
+// a file. A key in a slice marks the whole slice, which is one setting.
+type secrets map[string]bool
+
+func (s secrets) add(key string) {
+	if i := strings.IndexByte(key, '['); i >= 0 {
+		key = key[:i]
+	}
+	s[strings.ToLower(key)] = true
+}
+
+type loaded struct {
+	object  interface{}
+	origins origins
+	secrets secrets
+}
+
+// Explain returns the effective values of every object loaded by this
+// SuperAgent, in load order, with the origin of each key after layering,
+// environment overlay and defaults.
+func (s *SuperAgent) Explain() []Setting {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var settings []Setting
+	for _, l := range s.loaded {
+		flatten(reflect.ValueOf(l.object), "", false, func(key string, v reflect.Value, secret bool) {
+			secret = secret || l.secrets[strings.ToLower(key)]
+			setting := Setting{Key: key, Source: l.origins[strings.ToLower(key)], Secret: secret}
+			if secret {
+				setting.Value = Redacted
+			} else {
+				setting.Value = formatValue(v)
+			}
+			settings = append(settings, setting)
+		})
+	}
+	return settings
+}
+
+// flatten calls fn for every leaf of v. Structs and maps are walked, slices
+// and scalars are leaves.
+func flatten(v reflect.Value, key string, secret bool, fn func(key string, v reflect.Value, secret bool)) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if key != "" {
+				fn(key, v, secret)
+			}
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Kind() == reflect.Struct && !reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) && v.Type() != reflect.TypeOf(time.Time{}):
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := key
+			if !field.Anonymous {
+				name = joinPath(key, fieldName(field))
+			}
+			tag, ok := field.Tag.Lookup("secret")
+			flatten(v.Field(i), name, secret || (ok && tag != "false"), fn)
+		}
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			flatten(v.MapIndex(k), joinPath(key, k.String()), secret, fn)
+		}
+	default:
+		fn(key, v, secret)
+	}
+}
+
+func formatValue(v reflect.Value) string {
+	if !v.IsValid() || ((v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()) {
+		return "null"
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	return string(data)
+}
+
+// fileOrigins returns the source of every key in a config file, with line
+// numbers for YAML and JSON.
+func fileOrigins(filename string, data []byte, ext string) origins {
+	o := origins{}
+	name := filepath.Base(filename)
+
+	switch normalizeExt(ext) {
+	case ".yaml", ".yml":
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err == nil {
+			yamlOrigins(&node, "", name, o)
+			return o
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if jsonOrigins(dec, data, "", name, o) == nil {
+			return o
+		}
+	}
+
+	f, err := lookupFormat(ext)
+	if err != nil {
+		return o
+	}
+	var tree map[string]interface{}
+	if err := f.unmarshalTree(data, &tree); err != nil {
+		return o
+	}
+	flatten(reflect.ValueOf(tree), "", false, func(key string, _ reflect.Value, _ bool) {
+		o.set(key, name)
+	})
+	return o
+}
+
+func yamlOrigins(node *yaml.Node, key, name string, o origins) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			yamlOrigins(n, key, name, o)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			path := joinPath(key, k.Value)
+			if v.Kind == yaml.MappingNode {
+				yamlOrigins(v, path, name, o)
+				continue
+			}
+			o.set(path, fmt.Sprintf("%s:%d", name, k.Line))
+		}
+	}
+}
+
+// jsonOrigins reads one JSON value from dec and records the line of every
+// object key.
+func jsonOrigins(dec *json.Decoder, data []byte, key, name string, o origins) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			path := joinPath(key, fmt.Sprint(tok))
+			line := 1 + bytes.Count(data[:dec.InputOffset()], []byte("\n"))
+			o.set(path, fmt.Sprintf("%s:%d", name, line))
+			if err := jsonOrigins(dec, data, path, name, o); err != nil {
+				return err
+			}
+		}
+	case '[':
+		for dec.More() {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
@@ -1 +1,227 @@
 package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
+)
+
+// UnmarshalFunc decodes data into the value pointed to by v.
+type UnmarshalFunc func(data []byte, v interface{}) error
+
+// MarshalFunc encodes v, it is used to re-encode merged layers.
+type MarshalFunc func(v interface{}) ([]byte, error)
+
+type format struct {
+	unmarshal UnmarshalFunc
+	marshal   MarshalFunc
+	// tree optionally replaces unmarshal when decoding generic trees
+	tree UnmarshalFunc
+	// flat formats have no lists for SliceAppend to append
+	flat bool
+}
+
+// unmarshalTree decodes data into a generic tree for merging.
+func (f format) unmarshalTree(data []byte, tree *map[string]interface{}) error {
+	if f.tree != nil {
+		return f.tree(data, tree)
+	}
+	return f.unmarshal(data, tree)
+}
+
+var formats = struct {
+	sync.RWMutex
+	m map[string]format
+}{m: map[string]format{}}
+
+func init() {
+	formats.m[".json"] = format{unmarshal: json.Unmarshal, marshal: json.Marshal, tree: unmarshalJSONTree}
+	RegisterFormat(".yaml", yaml.Unmarshal, yaml.Marshal)
+	RegisterFormat(".yml", yaml.Unmarshal, yaml.Marshal)
+	RegisterFormat(".toml", toml.Unmarshal, marshalTOML)
+	formats.m[".ini"] = format{unmarshal: unmarshalINI, marshal: marshalINI, flat: true}
+	formats.m[".hcl"] = format{unmarshal: hcl.Unmarshal, marshal: marshalHCL, tree: unmarshalHCLTree}
+}
+
+// RegisterFormat registers the decoder used for files with the given
+// extension, replacing any previous one. The extension is case-insensitive
+// and the leading dot is optional.
+//
+// The optional encoder enables deep merging of layered files, formats
+// without one are decoded layer by layer into the same object and do not
+// support SliceAppend.
+func RegisterFormat(ext string, fn UnmarshalFunc, marshal ...MarshalFunc) {
+	formats.Lock()
+	defer formats.Unlock()
+
+	f := format{unmarshal: fn}
+	if len(marshal) > 0 {
+		f.marshal = marshal[0]
+	}
+	formats.m[normalizeExt(ext)] = f
+}
+
+func lookupFormat(ext string) (format, error) {
+	formats.RLock()
+	defer formats.RUnlock()
+
+	f, ok := formats.m[normalizeExt(ext)]
+	if !ok || f.unmarshal == nil {
+		return format{}, fmt.Errorf("format of %s not supportted", ext)
+	}
+	return f, nil
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// unmarshalJSONTree keeps large integers intact until the final decode.
+func unmarshalJSONTree(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func marshalTOML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalINI maps ini sections onto structs with ini.MapTo. Generic maps
+// get the keys of the default section at the top level and one nested map
+// per section.
+func unmarshalINI(data []byte, v interface{}) error {
+	f, err := ini.Load(data)
+	if err != nil {
+		return err
+	}
+
+	switch out := v.(type) {
+	case *map[string]interface{}:
+		if *out == nil {
+			*out = map[string]interface{}{}
+		}
+		iniToMap(f, *out)
+		return nil
+	case *interface{}:
+		m := map[string]interface{}{}
+		iniToMap(f, m)
+		*out = m
+		return nil
+	default:
+		return f.MapTo(v)
+	}
+}
+
+func iniToMap(f *ini.File, m map[string]interface{}) {
+	for _, section := range f.Sections() {
+		target := m
+		if section.Name() != ini.DefaultSection {
+			sub, ok := m[section.Name()].(map[string]interface{})
+			if !ok {
+				sub = map[string]interface{}{}
+				m[section.Name()] = sub
+			}
+			target = sub
+		}
+		for _, key := range section.Keys() {
+			target[key.Name()] = key.Value()
+		}
+	}
+}
+
+// marshalINI writes the top level values of a generic tree to the default
+// section and its maps to sections. Lists are comma separated, the way
+// ini.MapTo reads slices.
+func marshalINI(v interface{}) ([]byte, error) {
+	tree, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("ini: cannot encode %T", v)
+	}
+
+	f := ini.Empty()
+	for _, name := range sortedKeys(tree) {
+		section, ok := tree[name].(map[string]interface{})
+		if !ok {
+			if err := setINIKey(f.Section(ini.DefaultSection), name, tree[name]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, key := range sortedKeys(section) {
+			if err := setINIKey(f.Section(name), key, section[key]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func setINIKey(section *ini.Section, key string, v interface{}) error {
+	value := fmt.Sprint(v)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return fmt.Errorf("ini: %s.%s: sections cannot be nested", section.Name(), key)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, part := range v {
+			parts[i] = fmt.Sprint(part)
+		}
+		value = strings.Join(parts, ",")
+	}
+	_, err := section.NewKey(key, value)
+	return err
+}
+
+// unmarshalHCLTree unwraps the blocks that HCL decodes as lists of maps, so
+// blocks of later layers are merged key by key. Repeated blocks are merged
+// in order.
+func unmarshalHCLTree(data []byte, v interface{}) error {
+	if err := hcl.Unmarshal(data, v); err != nil {
+		return err
+	}
+	if tree, ok := v.(*map[string]interface{}); ok {
+		unwrapHCLBlocks(*tree)
+	}
+	return nil
+}
+
+func unwrapHCLBlocks(m map[string]interface{}) {
+	for key, value := range m {
+		blocks, ok := value.([]map[string]interface{})
+		if !ok {
+			continue
+		}
+		var block map[string]interface{}
+		for _, b := range blocks {
+			unwrapHCLBlocks(b)
+			block = mergeMap(block, b, false)
+		}
+		m[key] = block
+	}
+}
+
+// marshalHCL encodes merged trees as JSON, which hcl.Unmarshal reads too.
+func marshalHCL(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
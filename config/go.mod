@@ -3,6 +3,15 @@ module github.com/20326/flexbox/config
 go 1.18
 
 require (
+	github.com/20326/flexbox v0.0.0
+	github.com/BurntSushi/toml v1.3.2
+	github.com/fsnotify/fsnotify v1.6.0
+	github.com/hashicorp/hcl v1.0.0
 	github.com/joho/godotenv v1.5.1
+	gopkg.in/ini.v1 v1.67.0
 	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.0.0-20220908164124-27713097b956 // indirect
+
+replace github.com/20326/flexbox => ../
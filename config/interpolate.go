@@ -0,0 +1,301 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/20326/flexbox/pkg/defaults"
+)
+
+// filePrefix marks a variable that is read from a file, e.g.
+// `password: ${file:/run/secrets/db_pass}`.
+const filePrefix = "file:"
+
+// interpolate expands the variables in a string value. Values are looked
+// up in the process environment, which includes the variables loaded from
+// `.env`. Only decoded string values are expanded, so a variable cannot
+// change the structure of a file and comments are left alone.
+//
+//	${VAR}          value of VAR, an error if it is not set
+//	${VAR:-default} default if VAR is unset or empty
+//	${VAR-default}  default if VAR is unset
+//	${VAR:?message} an error with message if VAR is unset or empty
+//	${VAR?message}  an error with message if VAR is unset
+//	${file:/path}   content of the file, without the trailing newline
+//	$${VAR}         a literal ${VAR}
+//
+// fromFile reports whether a file was read.
+func interpolate(data string) (value string, fromFile bool, errs []error) {
+	if !strings.Contains(data, "${") {
+		return data, false, nil
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(data); {
+		if data[i] != '$' || i+1 >= len(data) {
+			out.WriteByte(data[i])
+			i++
+			continue
+		}
+		if data[i+1] == '$' && i+2 < len(data) && data[i+2] == '{' {
+			out.WriteString("${")
+			i += 3
+			continue
+		}
+		if data[i+1] != '{' {
+			out.WriteByte(data[i])
+			i++
+			continue
+		}
+
+		end := strings.IndexByte(data[i+2:], '}')
+		if end < 0 {
+			out.WriteString(data[i:])
+			break
+		}
+		expr := data[i+2 : i+2+end]
+		fromFile = fromFile || strings.HasPrefix(expr, filePrefix)
+		value, err := expand(expr)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		out.WriteString(value)
+		i += end + 3
+	}
+	return out.String(), fromFile, errs
+}
+
+// interpolateTree expands the string values of a generic tree walking it
+// along t. Expanded values at bool and number positions are parsed, so
+// `port: ${PORT}` decodes into an int field. The paths of values read from
+// files are added to files.
+func interpolateTree(tree interface{}, t reflect.Type, path string, files secrets) (interface{}, []error) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var errs []error
+	switch tree := tree.(type) {
+	case string:
+		if !strings.Contains(tree, "${") {
+			return tree, nil
+		}
+		value, fromFile, errs := interpolate(tree)
+		if fromFile {
+			files.add(path)
+		}
+		typed, err := typedValue(value, t)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", path, err))
+		}
+		return typed, errs
+	case map[string]interface{}:
+		// sorted, so errors are reported in a stable order
+		for _, key := range sortedKeys(tree) {
+			v, e := interpolateTree(tree[key], elemType(t, key), joinPath(path, key), files)
+			tree[key] = v
+			errs = append(errs, e...)
+		}
+	case []interface{}:
+		for i, value := range tree {
+			v, e := interpolateTree(value, elemType(t, ""), fmt.Sprintf("%s[%d]", path, i), files)
+			tree[i] = v
+			errs = append(errs, e...)
+		}
+	case []map[string]interface{}:
+		for i, value := range tree {
+			_, e := interpolateTree(value, elemType(t, ""), fmt.Sprintf("%s[%d]", path, i), files)
+			errs = append(errs, e...)
+		}
+	}
+	return tree, errs
+}
+
+// elemType returns the type of the value at key of a struct or map t, or
+// of the elements of a slice t. It is nil when unknown.
+func elemType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if field, ok := lookupField(t, key); ok {
+			return field.Type
+		}
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return t.Elem()
+	}
+	return nil
+}
+
+// typedValue parses an expanded value for a bool or number of type t,
+// other values stay strings.
+func typedValue(value string, t reflect.Type) (interface{}, error) {
+	if t == nil || t == durationType || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return value, nil
+	}
+
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		if err := defaults.SetValue(v, value); err != nil {
+			return nil, err
+		}
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if err := defaults.SetValue(v, value); err != nil {
+			return nil, err
+		}
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if err := defaults.SetValue(v, value); err != nil {
+			return nil, err
+		}
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		if err := defaults.SetValue(v, value); err != nil {
+			return nil, err
+		}
+		return v.Float(), nil
+	}
+	return value, nil
+}
+
+func expand(expr string) (string, error) {
+	if strings.HasPrefix(expr, filePrefix) {
+		data, err := os.ReadFile(strings.TrimPrefix(expr, filePrefix))
+		if err != nil {
+			return "", fmt.Errorf("variable ${%s}: %v", expr, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	name, op, arg := expr, "", ""
+	if i := strings.IndexAny(expr, ":-?"); i >= 0 {
+		name, op = expr[:i], expr[i:]
+		if strings.HasPrefix(op, ":") && len(op) > 1 {
+			op, arg = op[:2], op[2:]
+		} else {
+			op, arg = op[:1], op[1:]
+		}
+	}
+
+	value, ok := os.LookupEnv(name)
+	switch op {
+	case "":
+		if !ok {
+			return "", fmt.Errorf("unresolved variable %s", name)
+		}
+	case ":-":
+		if value == "" {
+			value = arg
+		}
+	case "-":
+		if !ok {
+			value = arg
+		}
+	case ":?":
+		if value == "" {
+			return "", fmt.Errorf("variable %s: %s", name, requiredMessage(arg))
+		}
+	case "?":
+		if !ok {
+			return "", fmt.Errorf("variable %s: %s", name, requiredMessage(arg))
+		}
+	default:
+		return "", fmt.Errorf("invalid variable expression ${%s}", expr)
+	}
+	return value, nil
+}
+
+func requiredMessage(msg string) string {
+	if msg == "" {
+		return "is required"
+	}
+	return msg
+}
+
+// interpolateObject expands the string values of a decoded object, for
+// formats that cannot be interpolated on their generic tree. The names of
+// values read from files are added to files.
+func interpolateObject(object interface{}, files secrets) []error {
+	return walkStrings(object, func(value, name string) (string, []error) {
+		value, fromFile, errs := interpolate(value)
+		if fromFile {
+			files.add(name)
+		}
+		return value, errs
+	})
+}
+
+// walkStrings replaces every settable string in object with the result of
+// fn, name is the path of the string.
+func walkStrings(object interface{}, fn func(value, name string) (string, []error)) []error {
+	v := reflect.ValueOf(object)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil
+	}
+	return walkValue(v.Elem(), "", fn)
+}
+
+func walkValue(v reflect.Value, name string, fn func(value, name string) (string, []error)) []error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return walkValue(v.Elem(), name, fn)
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		// interface values are not addressable, walk a copy
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		errs := walkValue(elem, name, fn)
+		if v.CanSet() {
+			v.Set(elem)
+		}
+		return errs
+	case reflect.String:
+		if !v.CanSet() {
+			return nil
+		}
+		value, errs := fn(v.String(), name)
+		v.SetString(value)
+		return errs
+	case reflect.Struct:
+		var errs []error
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			fieldPath := name
+			if !field.Anonymous {
+				fieldPath = joinPath(name, fieldName(field))
+			}
+			errs = append(errs, walkValue(v.Field(i), fieldPath, fn)...)
+		}
+		return errs
+	case reflect.Slice, reflect.Array:
+		var errs []error
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, walkValue(v.Index(i), fmt.Sprintf("%s[%d]", name, i), fn)...)
+		}
+		return errs
+	case reflect.Map:
+		var errs []error
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			errs = append(errs, walkValue(elem, joinPath(name, fmt.Sprint(iter.Key().Interface())), fn)...)
+			v.SetMapIndex(iter.Key(), elem)
+		}
+		return errs
+	}
+	return nil
+}
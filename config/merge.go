@@ -0,0 +1,116 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// decode decodes the layers in order into object, later layers win.
+// Variable errors are added to s, see interpolate, and the keys of values
+// read from files to files.
+//
+// Formats with an encoder are merged key by key on their generic form and
+// decoded once, so nested maps and slices follow the merge rules, and
+// variables and duration strings are expanded on that form. Other formats
+// are decoded one after another into the same object and only their
+// string values are expanded.
+func (s *SuperAgent) decode(layers [][]byte, ext string, object interface{}, files secrets) error {
+	f, err := lookupFormat(ext)
+	if err != nil {
+		return err
+	}
+
+	t := reflect.TypeOf(object)
+	durations := t != nil && hasDuration(t)
+	variables := false
+	for _, data := range layers {
+		variables = variables || bytes.Contains(data, []byte("${"))
+	}
+	if s.SliceAppend && len(layers) > 1 && (f.marshal == nil || f.flat) {
+		return fmt.Errorf("SliceAppend is not supported for %s files", normalizeExt(ext))
+	}
+	if f.marshal == nil || (len(layers) == 1 && !durations && !variables) {
+		for _, data := range layers {
+			if err := f.unmarshal(data, object); err != nil {
+				return err
+			}
+		}
+		if variables {
+			for _, err := range interpolateObject(object, files) {
+				s.addError(err)
+			}
+		}
+		return nil
+	}
+
+	var merged map[string]interface{}
+	for _, data := range layers {
+		var tree map[string]interface{}
+		if err := f.unmarshalTree(data, &tree); err != nil {
+			return err
+		}
+		merged = mergeMap(merged, tree, s.SliceAppend)
+	}
+
+	if variables {
+		_, errs := interpolateTree(merged, t, "", files)
+		for _, err := range errs {
+			s.addError(err)
+		}
+	}
+
+	if durations {
+		if _, err := normalizeDurations(merged, t, ""); err != nil {
+			return err
+		}
+	}
+
+	data, err := f.marshal(merged)
+	if err != nil {
+		return err
+	}
+	return f.unmarshal(data, object)
+}
+
+// mergeMap deep merges src into dst and returns dst. Nested maps are merged
+// key by key, slices are replaced unless appendSlices is set.
+func mergeMap(dst, src map[string]interface{}, appendSlices bool) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+
+	for key, sv := range src {
+		dv, ok := dst[key]
+		if !ok {
+			dst[key] = sv
+			continue
+		}
+
+		switch sv := sv.(type) {
+		case map[string]interface{}:
+			if dm, ok := dv.(map[string]interface{}); ok {
+				dst[key] = mergeMap(dm, sv, appendSlices)
+				continue
+			}
+		case []interface{}:
+			if ds, ok := dv.([]interface{}); ok && appendSlices {
+				dst[key] = append(ds[:len(ds):len(ds)], sv...)
+				continue
+			}
+		}
+		dst[key] = sv
+	}
+	return dst
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Size is a number of bytes which decodes from plain numbers or
+// human-readable strings like "512MB" or "1GiB".
+type Size int64
+
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"m":   1e6,
+	"mb":  1e6,
+	"g":   1e9,
+	"gb":  1e9,
+	"t":   1e12,
+	"tb":  1e12,
+	"p":   1e15,
+	"pb":  1e15,
+	"ki":  1 << 10,
+	"kib": 1 << 10,
+	"mi":  1 << 20,
+	"mib": 1 << 20,
+	"gi":  1 << 30,
+	"gib": 1 << 30,
+	"ti":  1 << 40,
+	"tib": 1 << 40,
+	"pi":  1 << 50,
+	"pib": 1 << 50,
+}
+
+// ParseSize parses a size such as "1024", "1.5KB" or "1GiB". Decimal units
+// (KB, MB, GB, TB, PB) are powers of 1000, binary units (KiB, MiB, GiB, TiB,
+// PiB) powers of 1024. Units are case-insensitive.
+func ParseSize(s string) (Size, error) {
+	raw := strings.TrimSpace(s)
+	i := strings.IndexFunc(raw, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(raw)
+	}
+
+	number, unit := raw[:i], strings.TrimSpace(raw[i:])
+	if number == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	factor, ok := sizeUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, unit)
+	}
+	return Size(n * factor), nil
+}
+
+// Bytes returns the size as int64.
+func (s Size) Bytes() int64 {
+	return int64(s)
+}
+
+func (s Size) String() string {
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Size) UnmarshalText(text []byte) error {
+	size, err := ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*s = size
+	return nil
+}
+
+// UnmarshalJSON accepts numbers and strings.
+func (s *Size) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch v := raw.(type) {
+	case float64:
+		*s = Size(v)
+		return nil
+	case string:
+		return s.UnmarshalText([]byte(v))
+	}
+	return fmt.Errorf("invalid size %s", data)
+}
+
+// UnmarshalYAML accepts numbers and strings.
+func (s *Size) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: invalid size", node.Line)
+	}
+	if err := s.UnmarshalText([]byte(node.Value)); err != nil {
+		return fmt.Errorf("line %d: %v", node.Line, err)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+name = "app"
+
+logger {
+  level   = "debug"
+  maxSize = 100
+}
+
+redis {
+  addrs       = ["127.0.0.1:6379"]
+  database    = 0
+  dialTimeout = "5s"
+}
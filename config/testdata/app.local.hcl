@@ -0,0 +1,3 @@
+redis {
+  database = 2
+}
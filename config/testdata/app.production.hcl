@@ -0,0 +1,7 @@
+logger {
+  level = "warn"
+}
+
+redis {
+  addrs = ["10.0.0.1:6379"]
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ValidationError describes a field that violates its `validate` tag.
+type ValidationError struct {
+	// Field is the dotted path of the field, e.g. `logger.maxSize`.
+	Field string
+	// Rule is the failed rule, e.g. `min=1`.
+	Rule    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// validate checks the `validate` tags of object, which are a comma
+// separated list of rules:
+//
+//	required      the value must not be zero
+//	min=N, max=N  bounds for numbers, durations and lengths of strings, slices and maps
+//	len=N         exact length of strings, slices and maps
+//	oneof=a b c   the value must be one of the space separated options
+func validate(object interface{}) []error {
+	v := reflect.ValueOf(object)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	return validateStruct(v, "")
+}
+
+func validateStruct(v reflect.Value, prefix string) []error {
+	var errs []error
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := prefix
+		if !field.Anonymous {
+			name = joinPath(prefix, fieldName(field))
+		}
+		fv := v.Field(i)
+
+		if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+			for _, rule := range strings.Split(tag, ",") {
+				if err := checkRule(fv, name, strings.TrimSpace(rule)); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+
+		errs = append(errs, validateNested(fv, name)...)
+	}
+	return errs
+}
+
+func validateNested(v reflect.Value, name string) []error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return validateNested(v.Elem(), name)
+	case reflect.Struct:
+		return validateStruct(v, name)
+	case reflect.Slice, reflect.Array:
+		var errs []error
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, validateNested(v.Index(i), fmt.Sprintf("%s[%d]", name, i))...)
+		}
+		return errs
+	}
+	return nil
+}
+
+func checkRule(v reflect.Value, name, rule string) error {
+	key, arg := rule, ""
+	if i := strings.IndexByte(rule, '='); i >= 0 {
+		key, arg = rule[:i], rule[i+1:]
+	}
+
+	fail := func(format string, args ...interface{}) error {
+		return &ValidationError{Field: name, Rule: rule, Message: fmt.Sprintf(format, args...)}
+	}
+
+	for v.Kind() == reflect.Ptr && key != "required" {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch key {
+	case "required":
+		if v.IsZero() {
+			return fail("is required")
+		}
+	case "min", "max", "len":
+		n, size, err := measure(v, arg)
+		if err != nil {
+			return fail("invalid rule %s: %v", rule, err)
+		}
+		switch {
+		case key == "min" && n < size:
+			return fail("must be at least %s", arg)
+		case key == "max" && n > size:
+			return fail("must be at most %s", arg)
+		case key == "len" && n != size:
+			return fail("must have length %s", arg)
+		}
+	case "oneof":
+		value := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(arg) {
+			if value == option {
+				return nil
+			}
+		}
+		return fail("must be one of [%s]", arg)
+	default:
+		return fail("unknown rule %s", rule)
+	}
+	return nil
+}
+
+// measure returns the value to compare against a min/max/len rule and the
+// parsed rule argument.
+func measure(v reflect.Value, arg string) (float64, float64, error) {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(arg)
+		return float64(v.Int()), float64(d), err
+	}
+
+	size, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), size, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), size, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), size, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), size, nil
+	}
+	return 0, 0, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var (
+	// WatchDebounce is the quiet period after the last write before a
+	// watched file is reloaded.
+	WatchDebounce = 100 * time.Millisecond
+
+	// WatchPollInterval is used when inotify is not available.
+	WatchPollInterval = time.Second
+)
+
+// A Watcher keeps an object in sync with its config files.
+type Watcher struct {
+	s        *SuperAgent
+	name     string
+	files    []string
+	object   interface{}
+	onChange func(old, new interface{})
+
+	mu      sync.Mutex
+	err     error
+	current atomic.Value
+
+	notify    *fsnotify.Watcher
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Watch loads name into object like LoadFile and reloads it whenever one of
+// its layers (name.ext, name.${env}.ext, name.local.ext) changes on disk.
+//
+// object receives the first value only and is never written again: the
+// fields of an object other goroutines read cannot be replaced atomically.
+// Reloads decode into a fresh value of the same type and swap it with the
+// one returned by Load, readers must go through Load to see it. Bursts of
+// writes are debounced and a broken file is reported by Err and leaves the
+// last good value in place. onChange is called with the old and the new
+// value after the swap, it may be nil.
+//
+// Watch returns a Watcher instead of only an error, it holds the current
+// value, the last reload error and Close to stop watching.
+func (s *SuperAgent) Watch(name string, object interface{}, onChange func(old, new interface{})) (*Watcher, error) {
+	rv := reflect.ValueOf(object)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, errors.New("watch object must be a non-nil pointer")
+	}
+
+	s.m.Lock()
+	base := path.Join(s.Path, name)
+	ext := filepath.Ext(name)
+	files := []string{
+		base,
+		path.Join(s.Path, strings.TrimSuffix(name, ext)+"."+s.Env+ext),
+		path.Join(s.Path, strings.TrimSuffix(name, ext)+".local"+ext),
+	}
+	s.m.Unlock()
+
+	w := &Watcher{
+		s:        s,
+		name:     name,
+		files:    files,
+		object:   object,
+		onChange: onChange,
+		done:     make(chan struct{}),
+	}
+
+	fresh, err := w.read()
+	if err != nil {
+		return nil, err
+	}
+	rv.Elem().Set(reflect.ValueOf(fresh).Elem())
+	w.current.Store(fresh)
+
+	notify, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = notify.Add(filepath.Dir(base))
+		if err != nil {
+			_ = notify.Close()
+		}
+	}
+	if err != nil {
+		log.Printf("Watch %s: %v, falling back to polling\n", base, err)
+		go w.poll()
+		return w, nil
+	}
+
+	w.notify = notify
+	go w.watch()
+	return w, nil
+}
+
+// Load returns a pointer to the current value, it is safe for concurrent
+// use. The value is replaced, never modified, on reload.
+func (w *Watcher) Load() interface{} {
+	return w.current.Load()
+}
+
+// Err returns the error of the last reload, nil if it succeeded.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.err
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		if w.notify != nil {
+			err = w.notify.Close()
+		}
+	})
+	return err
+}
+
+// read loads the files into a new value of the object type.
+func (w *Watcher) read() (interface{}, error) {
+	fresh := reflect.New(reflect.TypeOf(w.object).Elem()).Interface()
+	if errs := w.s.clone().LoadFile(w.name, fresh).End(); len(errs) > 0 {
+		return nil, fmt.Errorf("reload %s: %v", w.name, errs)
+	}
+	return fresh, nil
+}
+
+func (w *Watcher) reload() {
+	fresh, err := w.read()
+
+	w.mu.Lock()
+	w.err = err
+	if err != nil {
+		w.mu.Unlock()
+		log.Printf("Watch %v\n", err)
+		return
+	}
+
+	old := w.current.Load()
+	if reflect.DeepEqual(old, fresh) {
+		w.mu.Unlock()
+		return
+	}
+	w.current.Store(fresh)
+	w.mu.Unlock()
+
+	if w.onChange != nil {
+		w.onChange(old, fresh)
+	}
+}
+
+func (w *Watcher) watched(name string) bool {
+	name = filepath.Clean(name)
+	for _, file := range w.files {
+		if filepath.Clean(file) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Watcher) watch() {
+	timer := time.NewTimer(WatchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.notify.Events:
+			if !ok {
+				return
+			}
+			if w.watched(event.Name) {
+				timer.Reset(WatchDebounce)
+			}
+		case err, ok := <-w.notify.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Watch %s: %v\n", w.name, err)
+		case <-timer.C:
+			w.reload()
+		}
+	}
+}
+
+func (w *Watcher) poll() {
+	ticker := time.NewTicker(WatchPollInterval)
+	defer ticker.Stop()
+
+	last := w.stat()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if cur := w.stat(); cur != last {
+				last = cur
+				w.reload()
+			}
+		}
+	}
+}
+
+// stat returns a fingerprint of the modification time and size of all
+// layers, missing files included.
+func (w *Watcher) stat() string {
+	var b strings.Builder
+	for _, file := range w.files {
+		if info, err := os.Stat(file); err == nil {
+			fmt.Fprintf(&b, "%d:%d;", info.ModTime().UnixNano(), info.Size())
+		} else {
+			b.WriteString("-;")
+		}
+	}
+	return b.String()
+}
@@ -16,11 +16,11 @@ type MyConfig struct {
 }
 
 type loggerSection struct {
-	Level      string `yaml:"level"`
-	FileName   string `yaml:"fileName"`
-	MaxSize    int64  `yaml:"maxSize"`
-	MaxBackups int    `yaml:"maxBackups"`
-	Cron       string `yaml:"cron"`
+	Level      string      `yaml:"level"`
+	FileName   string      `yaml:"fileName"`
+	MaxSize    config.Size `yaml:"maxSize"`
+	MaxBackups int         `yaml:"maxBackups"`
+	Cron       string      `yaml:"cron"`
 }
 
 func main() {
@@ -37,7 +37,7 @@ func main() {
 		logger.WithLevel(cfg.Logger.Level),
 		logger.WithFileName(cfg.Logger.FileName),
 		logger.WithCronRunner(cfg.Logger.Cron),
-		logger.WithMaxSize(cfg.Logger.MaxSize),
+		logger.WithMaxSize(cfg.Logger.MaxSize.Bytes()),
 		logger.WithMaxBackups(cfg.Logger.MaxBackups),
 		logger.WithCronRunner(cfg.Logger.Cron),
 	)
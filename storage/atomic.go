@@ -0,0 +1,25 @@
+package storage
+
+import "time"
+
+// Atomic is implemented by storages with atomic read-modify-write
+// operations, the building blocks of locks and counters. Expired keys do
+// not exist for these operations.
+type Atomic interface {
+	// SetIfNotExists stores val for key with an expiration value, 0 means
+	// no expiration, if key does not exist. It reports whether val was
+	// stored.
+	SetIfNotExists(key string, val []byte, exp time.Duration) (bool, error)
+
+	// CompareAndSwap replaces the value of key with new and sets its
+	// expiration if the current value is old. A nil old swaps only if key
+	// does not exist, like SetIfNotExists. It reports whether new was
+	// stored.
+	CompareAndSwap(key string, old, new []byte, exp time.Duration) (bool, error)
+
+	// Increment adds delta to the counter at key and returns the new value.
+	// Counters are stored as decimal strings, a missing key starts at 0 and
+	// gets the expiration exp, existing keys keep theirs. Incrementing a
+	// value that is not an integer is an error.
+	Increment(key string, delta int64, exp time.Duration) (int64, error)
+}
@@ -0,0 +1,70 @@
+package storage
+
+import "time"
+
+// Entry is a value and its expiration for Batcher.SetMany, 0 means no
+// expiration.
+type Entry struct {
+	Value []byte
+	Exp   time.Duration
+}
+
+// Batcher is implemented by storages that read and write several keys in
+// one round trip.
+type Batcher interface {
+	// GetMany gets the values for the given keys. Keys that do not exist
+	// are missing from the result.
+	GetMany(keys []string) (map[string][]byte, error)
+
+	// SetMany stores the given entries. Empty keys or values will be
+	// ignored without an error.
+	SetMany(entries map[string]Entry) error
+
+	// DeleteMany deletes the values for the given keys.
+	DeleteMany(keys []string) error
+}
+
+// Batch returns s as a Batcher. Storages that implement it are returned as
+// is, other storages get one call per key.
+func Batch(s Storage) Batcher {
+	if b, ok := s.(Batcher); ok {
+		return b
+	}
+	return &batchAdapter{s: s}
+}
+
+type batchAdapter struct {
+	s Storage
+}
+
+func (a *batchAdapter) GetMany(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		val, err := a.s.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if val != nil {
+			values[key] = val
+		}
+	}
+	return values, nil
+}
+
+func (a *batchAdapter) SetMany(entries map[string]Entry) error {
+	for key, e := range entries {
+		if err := a.s.Set(key, e.Value, e.Exp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *batchAdapter) DeleteMany(keys []string) error {
+	for _, key := range keys {
+		if err := a.s.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	store := &slowStorage{db: map[string][]byte{}}
+	b := Batch(store)
+
+	err := b.SetMany(map[string]Entry{
+		"john": {Value: []byte("doe")},
+		"jane": {Value: []byte("roe"), Exp: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("Expected %v, but got %v", nil, err)
+	}
+
+	values, err := b.GetMany([]string{"john", "jane", "doe"})
+	expected := map[string][]byte{"john": []byte("doe"), "jane": []byte("roe")}
+	if err != nil || !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, but got %v %v", expected, values, err)
+	}
+
+	if err := b.DeleteMany([]string{"john", "jane"}); err != nil {
+		t.Fatalf("Expected %v, but got %v", nil, err)
+	}
+	if len(store.db) != 0 {
+		t.Errorf("Expected %v, but got %v", 0, len(store.db))
+	}
+}
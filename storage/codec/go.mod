@@ -0,0 +1,15 @@
+module storage/codec
+
+go 1.18
+
+require (
+	github.com/20326/flexbox v0.0.0
+	github.com/golang/snappy v0.0.4
+	github.com/klauspost/compress v1.13.6
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	google.golang.org/protobuf v1.30.0
+)
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+
+replace github.com/20326/flexbox => ../..
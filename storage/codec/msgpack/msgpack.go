@@ -0,0 +1,20 @@
+// Package msgpack provides a MessagePack storage.Codec for storage.Typed.
+package msgpack
+
+import (
+	"github.com/20326/flexbox/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes values with MessagePack
+var Codec storage.Codec = codec{}
+
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
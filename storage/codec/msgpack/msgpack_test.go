@@ -0,0 +1,24 @@
+package msgpack
+
+import (
+	"reflect"
+	"testing"
+)
+
+type user struct {
+	Name string
+	Tags []string
+}
+
+func TestCodec(t *testing.T) {
+	expected := user{Name: "john", Tags: []string{"admin"}}
+	data, err := Codec.Marshal(expected)
+	if err != nil {
+		t.Fatalf("Expected %v, but got %v", nil, err)
+	}
+
+	var val user
+	if err = Codec.Unmarshal(data, &val); err != nil || !reflect.DeepEqual(val, expected) {
+		t.Errorf("Expected %v, but got %v %v", expected, val, err)
+	}
+}
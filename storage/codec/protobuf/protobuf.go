@@ -0,0 +1,47 @@
+// Package protobuf provides a Protocol Buffers storage.Codec for
+// storage.Typed, the type of the values must be a pointer to a generated
+// message, e.g. storage.NewTyped[*pb.User].
+package protobuf
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/20326/flexbox/storage"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec encodes values with Protocol Buffers
+var Codec storage.Codec = codec{}
+
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal decodes data into v, a proto.Message or a pointer to one that
+// is allocated if nil
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Ptr {
+		return fmt.Errorf("protobuf: %T is not a pointer to a proto.Message", v)
+	}
+	elem := rv.Elem()
+	if elem.IsNil() {
+		elem.Set(reflect.New(elem.Type().Elem()))
+	}
+	m, ok := elem.Interface().(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: %T is not a pointer to a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
@@ -0,0 +1,34 @@
+package protobuf
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodec(t *testing.T) {
+	data, err := Codec.Marshal(wrapperspb.String("doe"))
+	if err != nil {
+		t.Fatalf("Expected %v, but got %v", nil, err)
+	}
+
+	// storage.Typed[*wrapperspb.StringValue] passes a pointer to a nil
+	// message
+	var val *wrapperspb.StringValue
+	if err = Codec.Unmarshal(data, &val); err != nil || val.GetValue() != "doe" {
+		t.Errorf("Expected %v, but got %v %v", "doe", val.GetValue(), err)
+	}
+
+	msg := &wrapperspb.StringValue{}
+	if err = Codec.Unmarshal(data, msg); err != nil || msg.GetValue() != "doe" {
+		t.Errorf("Expected %v, but got %v %v", "doe", msg.GetValue(), err)
+	}
+
+	if _, err = Codec.Marshal("doe"); err == nil {
+		t.Errorf("Expected an error, but got %v", err)
+	}
+	var s string
+	if err = Codec.Unmarshal(data, &s); err == nil {
+		t.Errorf("Expected an error, but got %v", err)
+	}
+}
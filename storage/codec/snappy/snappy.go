@@ -0,0 +1,20 @@
+// Package snappy provides a Snappy storage.Compressor for storage.Typed.
+package snappy
+
+import (
+	"github.com/20326/flexbox/storage"
+	"github.com/golang/snappy"
+)
+
+// Compressor compresses values with Snappy
+var Compressor storage.Compressor = compressor{}
+
+type compressor struct{}
+
+func (compressor) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (compressor) Decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
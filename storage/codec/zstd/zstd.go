@@ -0,0 +1,44 @@
+// Package zstd provides a Zstandard storage.Compressor for storage.Typed.
+package zstd
+
+import (
+	"sync"
+
+	"github.com/20326/flexbox/storage"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressor compresses values with Zstandard at the default level
+var Compressor storage.Compressor = &compressor{}
+
+// compressor creates its encoder and decoder on first use, they are safe
+// for concurrent use with EncodeAll and DecodeAll
+type compressor struct {
+	once sync.Once
+	enc  *zstd.Encoder
+	dec  *zstd.Decoder
+	err  error
+}
+
+func (c *compressor) init() {
+	c.once.Do(func() {
+		if c.enc, c.err = zstd.NewWriter(nil); c.err != nil {
+			return
+		}
+		c.dec, c.err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	})
+}
+
+func (c *compressor) Compress(data []byte) ([]byte, error) {
+	if c.init(); c.err != nil {
+		return nil, c.err
+	}
+	return c.enc.EncodeAll(data, nil), nil
+}
+
+func (c *compressor) Decompress(data []byte) ([]byte, error) {
+	if c.init(); c.err != nil {
+		return nil, c.err
+	}
+	return c.dec.DecodeAll(data, nil)
+}
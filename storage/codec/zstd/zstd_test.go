@@ -0,0 +1,23 @@
+package zstd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressor(t *testing.T) {
+	expected := bytes.Repeat([]byte("doe"), 1000)
+	data, err := Compressor.Compress(expected)
+	if err != nil || len(data) >= len(expected) {
+		t.Fatalf("Expected a compressed value, but got %d bytes %v", len(data), err)
+	}
+
+	val, err := Compressor.Decompress(data)
+	if err != nil || !bytes.Equal(val, expected) {
+		t.Errorf("Expected %d bytes, but got %d %v", len(expected), len(val), err)
+	}
+
+	if _, err = Compressor.Decompress([]byte("doe")); err == nil {
+		t.Errorf("Expected an error, but got %v", err)
+	}
+}
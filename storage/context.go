@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// WithContext returns s as a StorageWithContext. Storages that implement it
+// are returned as is, other calls run in their own goroutine and return
+// ctx.Err() as soon as ctx is done, the call itself is not interrupted.
+func WithContext(s Storage) StorageWithContext {
+	if sc, ok := s.(StorageWithContext); ok {
+		return sc
+	}
+	return &contextAdapter{s: s}
+}
+
+// WithTimeout returns a Storage that bounds every call to s with timeout,
+// 0 means no timeout.
+func WithTimeout(s StorageWithContext, timeout time.Duration) Storage {
+	return &timeoutAdapter{s: s, timeout: timeout}
+}
+
+type contextAdapter struct {
+	s Storage
+}
+
+// do runs fn and waits for it or ctx, whichever is first
+func do(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *contextAdapter) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	var val []byte
+	err := do(ctx, func() (err error) {
+		val, err = a.s.Get(key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (a *contextAdapter) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	return do(ctx, func() error {
+		return a.s.Set(key, val, exp)
+	})
+}
+
+func (a *contextAdapter) DeleteWithContext(ctx context.Context, key string) error {
+	return do(ctx, func() error {
+		return a.s.Delete(key)
+	})
+}
+
+func (a *contextAdapter) ResetWithContext(ctx context.Context) error {
+	return do(ctx, a.s.Reset)
+}
+
+func (a *contextAdapter) Close() error {
+	return a.s.Close()
+}
+
+type timeoutAdapter struct {
+	s       StorageWithContext
+	timeout time.Duration
+}
+
+func (a *timeoutAdapter) context() (context.Context, context.CancelFunc) {
+	if a.timeout <= 0 {
+		return context.Background(), func() {}
+	}
+	return context.WithTimeout(context.Background(), a.timeout)
+}
+
+func (a *timeoutAdapter) Get(key string) ([]byte, error) {
+	ctx, cancel := a.context()
+	defer cancel()
+	return a.s.GetWithContext(ctx, key)
+}
+
+func (a *timeoutAdapter) Set(key string, val []byte, exp time.Duration) error {
+	ctx, cancel := a.context()
+	defer cancel()
+	return a.s.SetWithContext(ctx, key, val, exp)
+}
+
+func (a *timeoutAdapter) Delete(key string) error {
+	ctx, cancel := a.context()
+	defer cancel()
+	return a.s.DeleteWithContext(ctx, key)
+}
+
+func (a *timeoutAdapter) Reset() error {
+	ctx, cancel := a.context()
+	defer cancel()
+	return a.s.ResetWithContext(ctx)
+}
+
+func (a *timeoutAdapter) Close() error {
+	return a.s.Close()
+}
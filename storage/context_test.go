@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// slowStorage is a Storage whose calls take delay
+type slowStorage struct {
+	delay time.Duration
+	db    map[string][]byte
+}
+
+func (s *slowStorage) Get(key string) ([]byte, error) {
+	time.Sleep(s.delay)
+	return s.db[key], nil
+}
+
+func (s *slowStorage) Set(key string, val []byte, exp time.Duration) error {
+	time.Sleep(s.delay)
+	s.db[key] = val
+	return nil
+}
+
+func (s *slowStorage) Delete(key string) error {
+	time.Sleep(s.delay)
+	delete(s.db, key)
+	return nil
+}
+
+func (s *slowStorage) Reset() error {
+	s.db = map[string][]byte{}
+	return nil
+}
+
+func (s *slowStorage) Close() error {
+	return nil
+}
+
+func TestWithContext(t *testing.T) {
+	store := WithContext(&slowStorage{db: map[string][]byte{}})
+
+	if err := store.SetWithContext(context.Background(), "john", []byte("doe"), 0); err != nil {
+		t.Fatalf("Expected %v, but got %v", nil, err)
+	}
+	val, err := store.GetWithContext(context.Background(), "john")
+	if err != nil || string(val) != "doe" {
+		t.Errorf("Expected %v, but got %v %v", "doe", string(val), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.GetWithContext(ctx, "john"); err != context.Canceled {
+		t.Errorf("Expected %v, but got %v", context.Canceled, err)
+	}
+
+	slow := WithContext(&slowStorage{delay: time.Second, db: map[string][]byte{}})
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := slow.GetWithContext(ctx, "john"); err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, but got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the call to return at the deadline, but it took %v", elapsed)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	store := WithTimeout(WithContext(&slowStorage{delay: time.Second, db: map[string][]byte{}}), 10*time.Millisecond)
+	if err := store.Set("john", []byte("doe"), 0); err != context.DeadlineExceeded {
+		t.Errorf("Expected %v, but got %v", context.DeadlineExceeded, err)
+	}
+
+	store = WithTimeout(WithContext(&slowStorage{db: map[string][]byte{}}), 0)
+	if err := store.Set("john", []byte("doe"), 0); err != nil {
+		t.Fatalf("Expected %v, but got %v", nil, err)
+	}
+	val, err := store.Get("john")
+	if err != nil || string(val) != "doe" {
+		t.Errorf("Expected %v, but got %v %v", "doe", string(val), err)
+	}
+}
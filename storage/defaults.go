@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/20326/flexbox/pkg/defaults"
+)
+
+// Defaults sets the zero fields of the struct config points to from their
+// `default` tag with defaults.Apply, the parser the config package uses
+// when loading a file. Drivers build their ConfigDefault with it, so every
+// default is declared once. It panics if a tag does not parse, the tags of
+// a driver are constants.
+func Defaults(config interface{}) {
+	defaults.Apply(config, func(path []reflect.StructField, err error) {
+		if err != nil {
+			panic(fmt.Sprintf("storage: default of %s: %v", path[len(path)-1].Name, err))
+		}
+	})
+}
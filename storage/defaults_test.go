@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDefaults(t *testing.T) {
+	type config struct {
+		Host    string        `default:"127.0.0.1"`
+		Port    int           `default:"6379"`
+		Addrs   []string      `default:"a:1, b:2"`
+		Timeout time.Duration `default:"10s"`
+		FIFO    bool          `default:"true"`
+		Name    string        `default:"fiber"`
+		Reset   bool
+	}
+
+	cfg := config{Name: "app"}
+	Defaults(&cfg)
+	expected := config{
+		Host:    "127.0.0.1",
+		Port:    6379,
+		Addrs:   []string{"a:1", "b:2"},
+		Timeout: 10 * time.Second,
+		FIFO:    true,
+		Name:    "app",
+	}
+	if !reflect.DeepEqual(expected, cfg) {
+		t.Errorf("Expected %v, but got %v", expected, cfg)
+	}
+}
+
+func TestDefaultsInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for an invalid tag, but got none")
+		}
+	}()
+	var cfg struct {
+		Port int `default:"port"`
+	}
+	Defaults(&cfg)
+}
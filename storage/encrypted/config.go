@@ -0,0 +1,69 @@
+package encrypted
+
+import "github.com/20326/flexbox/storage"
+
+// Ciphers for Config.Cipher
+const (
+	// AESGCM is AES in Galois/Counter Mode, the key size selects AES-128,
+	// AES-192 or AES-256
+	AESGCM = "aes-gcm"
+	// XChaCha20Poly1305 is XChaCha20-Poly1305 with a 32 byte key
+	XChaCha20Poly1305 = "xchacha20-poly1305"
+)
+
+// Key is an encryption key and the ID stored with every value it encrypts
+type Key struct {
+	// ID of the key, at most 255 bytes
+	ID string
+
+	// Secret of the key, 16, 24 or 32 bytes for AES-GCM and 32 bytes for
+	// XChaCha20-Poly1305
+	Secret []byte
+}
+
+// Config defines the config for storage.
+type Config struct {
+	// Cipher encrypting new values: "aes-gcm" or "xchacha20-poly1305".
+	// Values are decrypted with the cipher they were encrypted with
+	//
+	// Default is "aes-gcm"
+	Cipher string `yaml:"cipher" default:"aes-gcm"`
+
+	// Keys of the storage, the first one encrypts new values and all of
+	// them decrypt. Keep retired keys here until their values are
+	// rewritten or expired
+	//
+	// Required
+	Keys []Key `yaml:"-"`
+
+	// Secret to HMAC the keys with, so key names are not visible in the
+	// backend. Hashed keys cannot be scanned by prefix
+	//
+	// Optional. Default is nil, keys are stored as is
+	KeyHMAC []byte `yaml:"-"`
+}
+
+// ConfigDefault is the default config, built from the default tags of
+// Config
+var ConfigDefault = func() Config {
+	var cfg Config
+	storage.Defaults(&cfg)
+	return cfg
+}()
+
+// configDefault is a helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Cipher == "" {
+		cfg.Cipher = ConfigDefault.Cipher
+	}
+	return cfg
+}
@@ -0,0 +1,20 @@
+package encrypted
+
+import (
+	"testing"
+
+	"github.com/20326/flexbox/storage"
+	"github.com/20326/flexbox/storage/storagetest"
+
+	"storage/memory"
+)
+
+func Test_Encrypted_Conformance(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Storage {
+		store, err := New(memory.New(), Config{Keys: []Key{key1}, KeyHMAC: []byte("secret")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
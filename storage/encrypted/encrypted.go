@@ -0,0 +1,237 @@
+package encrypted
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/20326/flexbox/storage"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Values are stored as version, cipher, key ID length, key ID, nonce and
+// the sealed value. The header and the stored key are authenticated, so a
+// value cannot be moved to another key.
+const version byte = 1
+
+// Cipher IDs in the header
+const (
+	cipherAESGCM            byte = 1
+	cipherXChaCha20Poly1305 byte = 2
+)
+
+var (
+	// ErrUnknownKey is returned by Get when a value was encrypted with a
+	// key that is not in Config.Keys
+	ErrUnknownKey = errors.New("encrypted: unknown key ID")
+	// ErrInvalidValue is returned by Get when a value is not encrypted,
+	// was tampered with or belongs to another key
+	ErrInvalidValue = errors.New("encrypted: invalid value")
+)
+
+// Storage encrypts the values of another storage
+type Storage struct {
+	s      storage.Storage
+	sc     storage.StorageWithContext
+	cipher byte
+	active *key
+	keys   map[string]*key
+	hmac   []byte
+}
+
+// key holds an AEAD for every cipher its secret fits
+type key struct {
+	id    string
+	aeads map[byte]cipher.AEAD
+}
+
+// New wraps s, it fails if the config has no key or a key does not fit
+// Config.Cipher
+func New(s storage.Storage, config ...Config) (*Storage, error) {
+	// Set default config
+	cfg := configDefault(config...)
+
+	var id byte
+	switch cfg.Cipher {
+	case AESGCM:
+		id = cipherAESGCM
+	case XChaCha20Poly1305:
+		id = cipherXChaCha20Poly1305
+	default:
+		return nil, fmt.Errorf("encrypted: unknown cipher %q", cfg.Cipher)
+	}
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("encrypted: no key")
+	}
+
+	store := &Storage{
+		s:      s,
+		sc:     storage.WithContext(s),
+		cipher: id,
+		keys:   make(map[string]*key, len(cfg.Keys)),
+		hmac:   cfg.KeyHMAC,
+	}
+	for _, k := range cfg.Keys {
+		if len(k.ID) > 255 {
+			return nil, fmt.Errorf("encrypted: key ID %q is longer than 255 bytes", k.ID)
+		}
+		if _, ok := store.keys[k.ID]; ok {
+			return nil, fmt.Errorf("encrypted: duplicate key ID %q", k.ID)
+		}
+		store.keys[k.ID] = newKey(k)
+	}
+	store.active = store.keys[cfg.Keys[0].ID]
+	if _, ok := store.active.aeads[id]; !ok {
+		return nil, fmt.Errorf("encrypted: key %q does not fit %s", cfg.Keys[0].ID, cfg.Cipher)
+	}
+	return store, nil
+}
+
+func newKey(k Key) *key {
+	aeads := make(map[byte]cipher.AEAD, 2)
+	if block, err := aes.NewCipher(k.Secret); err == nil {
+		if aead, err := cipher.NewGCM(block); err == nil {
+			aeads[cipherAESGCM] = aead
+		}
+	}
+	if aead, err := chacha20poly1305.NewX(k.Secret); err == nil {
+		aeads[cipherXChaCha20Poly1305] = aead
+	}
+	return &key{id: k.ID, aeads: aeads}
+}
+
+// Get value by key
+func (s *Storage) Get(key string) ([]byte, error) {
+	return s.GetWithContext(context.Background(), key)
+}
+
+// Set key with value
+func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
+	return s.SetWithContext(context.Background(), key, val, exp)
+}
+
+// Delete key by key
+func (s *Storage) Delete(key string) error {
+	return s.DeleteWithContext(context.Background(), key)
+}
+
+// Reset all keys
+func (s *Storage) Reset() error {
+	return s.s.Reset()
+}
+
+// Close the wrapped storage
+func (s *Storage) Close() error {
+	return s.s.Close()
+}
+
+// GetWithContext gets and decrypts the value of key
+func (s *Storage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	if len(key) <= 0 {
+		return nil, nil
+	}
+	stored := s.key(key)
+	data, err := s.sc.GetWithContext(ctx, stored)
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	return s.open(stored, data)
+}
+
+// SetWithContext encrypts val with the first key and sets key with it
+func (s *Storage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(val) <= 0 {
+		return nil
+	}
+	stored := s.key(key)
+	data, err := s.seal(stored, val)
+	if err != nil {
+		return err
+	}
+	return s.sc.SetWithContext(ctx, stored, data, exp)
+}
+
+// DeleteWithContext deletes key by key
+func (s *Storage) DeleteWithContext(ctx context.Context, key string) error {
+	if len(key) <= 0 {
+		return nil
+	}
+	return s.sc.DeleteWithContext(ctx, s.key(key))
+}
+
+// ResetWithContext resets all keys
+func (s *Storage) ResetWithContext(ctx context.Context) error {
+	return s.sc.ResetWithContext(ctx)
+}
+
+// Conn returns the wrapped storage
+func (s *Storage) Conn() storage.Storage {
+	return s.s
+}
+
+// key returns the key stored in the backend, the hex HMAC-SHA256 of key if
+// Config.KeyHMAC is set
+func (s *Storage) key(key string) string {
+	if s.hmac == nil {
+		return key
+	}
+	mac := hmac.New(sha256.New, s.hmac)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Storage) seal(stored string, val []byte) ([]byte, error) {
+	aead := s.active.aeads[s.cipher]
+	header := 3 + len(s.active.id)
+	out := make([]byte, header+aead.NonceSize(), header+aead.NonceSize()+len(val)+aead.Overhead())
+	out[0] = version
+	out[1] = s.cipher
+	out[2] = byte(len(s.active.id))
+	copy(out[3:], s.active.id)
+
+	nonce := out[header:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, nonce, val, additionalData(out[:header], stored)), nil
+}
+
+func (s *Storage) open(stored string, data []byte) ([]byte, error) {
+	if len(data) < 3 || data[0] != version {
+		return nil, ErrInvalidValue
+	}
+	header := 3 + int(data[2])
+	if len(data) < header {
+		return nil, ErrInvalidValue
+	}
+	k, ok := s.keys[string(data[3:header])]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, data[3:header])
+	}
+	aead, ok := k.aeads[data[1]]
+	if !ok || len(data) < header+aead.NonceSize()+aead.Overhead() {
+		return nil, ErrInvalidValue
+	}
+
+	nonce := data[header : header+aead.NonceSize()]
+	val, err := aead.Open(nil, nonce, data[header+aead.NonceSize():], additionalData(data[:header], stored))
+	if err != nil {
+		return nil, ErrInvalidValue
+	}
+	return val, nil
+}
+
+// additionalData authenticates the header and the stored key
+func additionalData(header []byte, stored string) []byte {
+	ad := make([]byte, 0, len(header)+len(stored))
+	ad = append(ad, header...)
+	return append(ad, stored...)
+}
@@ -0,0 +1,134 @@
+package encrypted
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/gofiber/utils"
+
+	"storage/memory"
+)
+
+var (
+	key1 = Key{ID: "2023-01", Secret: bytes.Repeat([]byte{1}, 32)}
+	key2 = Key{ID: "2023-02", Secret: bytes.Repeat([]byte{2}, 32)}
+)
+
+func newStore(t *testing.T, backend *memory.Storage, config Config) *Storage {
+	store, err := New(backend, config)
+	utils.AssertEqual(t, nil, err)
+	return store
+}
+
+func Test_Encrypted_Set_Get(t *testing.T) {
+	for _, cipher := range []string{AESGCM, XChaCha20Poly1305} {
+		backend := memory.New()
+		store := newStore(t, backend, Config{Cipher: cipher, Keys: []Key{key1}})
+
+		utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+		result, err := store.Get("john")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, []byte("doe"), result)
+
+		raw, err := backend.Get("john")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, false, bytes.Contains(raw, []byte("doe")))
+
+		result, err = store.Get("jane")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, len(result) == 0)
+
+		utils.AssertEqual(t, nil, store.Delete("john"))
+		result, err = store.Get("john")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, len(result) == 0)
+		utils.AssertEqual(t, nil, store.Close())
+	}
+}
+
+func Test_Encrypted_Rotation(t *testing.T) {
+	backend := memory.New()
+	defer backend.Close()
+
+	old := newStore(t, backend, Config{Keys: []Key{key1}})
+	utils.AssertEqual(t, nil, old.Set("john", []byte("doe"), 0))
+
+	// During the rotation window both keys decrypt, new values use key2
+	store := newStore(t, backend, Config{Cipher: XChaCha20Poly1305, Keys: []Key{key2, key1}})
+	result, err := store.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), result)
+
+	utils.AssertEqual(t, nil, store.Set("jane", []byte("doe"), 0))
+	_, err = old.Get("jane")
+	utils.AssertEqual(t, true, errors.Is(err, ErrUnknownKey))
+
+	// After the window key1 is gone
+	store = newStore(t, backend, Config{Keys: []Key{key2}})
+	_, err = store.Get("john")
+	utils.AssertEqual(t, true, errors.Is(err, ErrUnknownKey))
+	result, err = store.Get("jane")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), result)
+}
+
+func Test_Encrypted_KeyHMAC(t *testing.T) {
+	backend := memory.New()
+	defer backend.Close()
+
+	store := newStore(t, backend, Config{Keys: []Key{key1}, KeyHMAC: []byte("secret")})
+	utils.AssertEqual(t, nil, store.Set("john@doe.com", []byte("doe"), 0))
+
+	raw, err := backend.Get("john@doe.com")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, len(raw) == 0)
+	utils.AssertEqual(t, 1, len(backend.Conn()))
+
+	result, err := store.Get("john@doe.com")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), result)
+}
+
+func Test_Encrypted_Tampering(t *testing.T) {
+	backend := memory.New()
+	defer backend.Close()
+
+	store := newStore(t, backend, Config{Keys: []Key{key1}})
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+	raw, err := backend.Get("john")
+	utils.AssertEqual(t, nil, err)
+
+	// A value moved to another key does not decrypt
+	utils.AssertEqual(t, nil, backend.Set("jane", raw, 0))
+	_, err = store.Get("jane")
+	utils.AssertEqual(t, ErrInvalidValue, err)
+
+	tampered := append([]byte(nil), raw...)
+	tampered[len(tampered)-1] ^= 1
+	utils.AssertEqual(t, nil, backend.Set("john", tampered, 0))
+	_, err = store.Get("john")
+	utils.AssertEqual(t, ErrInvalidValue, err)
+
+	utils.AssertEqual(t, nil, backend.Set("john", []byte("doe"), 0))
+	_, err = store.Get("john")
+	utils.AssertEqual(t, ErrInvalidValue, err)
+}
+
+func Test_Encrypted_New(t *testing.T) {
+	backend := memory.New()
+	defer backend.Close()
+
+	_, err := New(backend)
+	utils.AssertEqual(t, true, err != nil)
+
+	_, err = New(backend, Config{Cipher: "rot13", Keys: []Key{key1}})
+	utils.AssertEqual(t, true, err != nil)
+
+	// XChaCha20-Poly1305 needs a 32 byte key
+	_, err = New(backend, Config{Cipher: XChaCha20Poly1305, Keys: []Key{{ID: "short", Secret: make([]byte, 16)}}})
+	utils.AssertEqual(t, true, err != nil)
+
+	_, err = New(backend, Config{Keys: []Key{key1, key1}})
+	utils.AssertEqual(t, true, err != nil)
+}
@@ -0,0 +1,17 @@
+module storage/encrypted
+
+go 1.18
+
+require (
+	github.com/20326/flexbox v0.0.0
+	github.com/gofiber/utils v1.0.1
+	golang.org/x/crypto v0.17.0
+	storage/memory v0.0.0
+)
+
+require golang.org/x/sys v0.15.0 // indirect
+
+replace (
+	github.com/20326/flexbox => ../..
+	storage/memory => ../memory
+)
@@ -0,0 +1,15 @@
+package storage
+
+import "time"
+
+// Expirer is implemented by storages that can read and extend the
+// expiration of a key, e.g. for sliding expiration sessions.
+type Expirer interface {
+	// TTL returns the time left before the key expires. ok is false when
+	// the key does not exist, a key without expiration has a TTL of 0.
+	TTL(key string) (ttl time.Duration, ok bool, err error)
+
+	// Touch sets the expiration of an existing key to exp from now, 0
+	// means no expiration. It returns no error if the key does not exist.
+	Touch(key string, exp time.Duration) error
+}
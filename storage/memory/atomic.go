@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SetIfNotExists sets key with value if key does not exist, it reports
+// whether the value was set
+func (s *Storage) SetIfNotExists(key string, val []byte, exp time.Duration) (bool, error) {
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(val) <= 0 {
+		return false, nil
+	}
+	c := newClock()
+
+	sh := s.shard(key)
+	sh.mux.Lock()
+	if _, ok := sh.live(key, &c); ok {
+		sh.mux.Unlock()
+		return false, nil
+	}
+	evicted := s.put(sh, key, entry{val, c.expiry(exp)})
+	sh.mux.Unlock()
+	s.notify(evicted)
+	return true, nil
+}
+
+// CompareAndSwap sets key to new if its value is old, a nil old means key
+// must not exist. It reports whether the value was swapped
+func (s *Storage) CompareAndSwap(key string, old, new []byte, exp time.Duration) (bool, error) {
+	if old == nil {
+		return s.SetIfNotExists(key, new, exp)
+	}
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(new) <= 0 {
+		return false, nil
+	}
+	c := newClock()
+
+	sh := s.shard(key)
+	sh.mux.Lock()
+	v, ok := sh.live(key, &c)
+	if !ok || !bytes.Equal(v.data, old) {
+		sh.mux.Unlock()
+		return false, nil
+	}
+	evicted := s.put(sh, key, entry{new, c.expiry(exp)})
+	sh.mux.Unlock()
+	s.notify(evicted)
+	return true, nil
+}
+
+// Increment adds delta to the counter at key and returns the new value, a
+// missing key starts at 0 and expires after exp
+func (s *Storage) Increment(key string, delta int64, exp time.Duration) (int64, error) {
+	if len(key) <= 0 {
+		return 0, nil
+	}
+	c := newClock()
+
+	sh := s.shard(key)
+	sh.mux.Lock()
+	v, ok := sh.live(key, &c)
+	if !ok {
+		v = entry{expiry: c.expiry(exp)}
+	}
+
+	var n int64
+	if ok {
+		var err error
+		if n, err = strconv.ParseInt(string(v.data), 10, 64); err != nil {
+			sh.mux.Unlock()
+			return 0, fmt.Errorf("memory: value of %q is not an integer", key)
+		}
+	}
+	n += delta
+	v.data = strconv.AppendInt(nil, n, 10)
+	evicted := s.put(sh, key, v)
+	sh.mux.Unlock()
+	s.notify(evicted)
+	return n, nil
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"github.com/20326/flexbox/storage"
+)
+
+// GetMany gets the values of keys with a single lock per shard, missing
+// and expired keys are left out
+func (s *Storage) GetMany(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	c := newClock()
+	for sh, keys := range s.group(keys) {
+		sh.mux.RLock()
+		for _, key := range keys {
+			v, ok := sh.live(key, &c)
+			s.hit(sh, key, ok)
+			if ok {
+				values[key] = v.data
+			}
+		}
+		sh.mux.RUnlock()
+	}
+	return values, nil
+}
+
+// SetMany sets all entries with a single lock per shard
+func (s *Storage) SetMany(entries map[string]storage.Entry) error {
+	keys := make([]string, 0, len(entries))
+	for key, e := range entries {
+		// Ain't Nobody Got Time For That
+		if len(key) <= 0 || len(e.Value) <= 0 {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	var evicted []evicted
+	c := newClock()
+	for sh, keys := range s.group(keys) {
+		sh.mux.Lock()
+		for _, key := range keys {
+			e := entries[key]
+			evicted = append(evicted, s.put(sh, key, entry{e.Value, c.expiry(e.Exp)})...)
+		}
+		sh.mux.Unlock()
+	}
+	s.notify(evicted)
+	return nil
+}
+
+// DeleteMany deletes all keys with a single lock per shard
+func (s *Storage) DeleteMany(keys []string) error {
+	for sh, keys := range s.group(keys) {
+		sh.mux.Lock()
+		for _, key := range keys {
+			sh.remove(key)
+		}
+		sh.mux.Unlock()
+	}
+	return nil
+}
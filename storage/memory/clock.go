@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"sync/atomic"
+	"time"
+
+	"storage/memory/internal"
+)
+
+// coarseTTL is the shortest TTL whose expiry is computed from the cheap
+// timestamp, it is off by less than a second. Shorter TTLs read the clock.
+const coarseTTL = time.Minute
+
+// coarseSlack is how far in milliseconds the cheap timestamp may be behind
+// the clock, expiries further away are decided without reading the clock
+const coarseSlack = 2000
+
+// clock is the time of a single operation in unix milliseconds. It starts
+// from the cheap timestamp updated every second and reads the clock at
+// most once, only for expiries close to now and short TTLs.
+type clock struct {
+	coarse int64
+	now    int64
+}
+
+func newClock() clock {
+	return clock{coarse: int64(atomic.LoadUint32(&internal.Timestamp)) * 1000}
+}
+
+// ms returns the precise time
+func (c *clock) ms() int64 {
+	if c.now == 0 {
+		c.now = time.Now().UnixMilli()
+	}
+	return c.now
+}
+
+// live reports whether expiry has not passed, 0 means no expiration
+func (c *clock) live(expiry int64) bool {
+	switch {
+	case expiry == 0 || expiry > c.coarse+coarseSlack:
+		return true
+	case expiry <= c.coarse:
+		return false
+	default:
+		return expiry > c.ms()
+	}
+}
+
+// expiry returns the expiry of exp from now, 0 means no expiration. TTLs
+// below a millisecond are rounded up, so they never mean no expiration.
+func (c *clock) expiry(exp time.Duration) int64 {
+	if exp == 0 {
+		return 0
+	}
+	if exp >= coarseTTL {
+		return c.coarse + exp.Milliseconds()
+	}
+	ms := exp.Milliseconds()
+	if ms == 0 {
+		ms = 1
+	}
+	return c.ms() + ms
+}
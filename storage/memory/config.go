@@ -1,20 +1,84 @@
 package memory
 
-import "time"
+import (
+	"time"
+
+	"github.com/20326/flexbox/storage"
+)
 
 // Config defines the config for storage.
 type Config struct {
 	// Time before deleting expired keys
 	//
 	// Default is 10 * time.Second
-	GCInterval time.Duration
-}
+	GCInterval time.Duration `yaml:"gcInterval" default:"10s"`
+
+	// Number of shards, each with its own lock, rounded up to a power of
+	// two. MaxEntries and MaxBytes are split between the shards, a shard
+	// evicts keys when its share is reached even if the others have room.
+	// Shards is halved until it is not larger than the limits
+	//
+	// Default is 1
+	Shards int `yaml:"shards" default:"1"`
+
+	// Maximum number of keys, keys are evicted when it is reached
+	//
+	// Default is 0, no limit
+	MaxEntries int `yaml:"maxEntries"`
+
+	// Maximum size of all keys and values in bytes, keys are evicted when
+	// it is reached. A key and value larger than the share of their shard
+	// are evicted right away
+	//
+	// Default is 0, no limit
+	MaxBytes int64 `yaml:"maxBytes"`
+
+	// Eviction policy when MaxEntries or MaxBytes is reached: "lru",
+	// "lfu" or "random"
+	//
+	// Default is "lru"
+	Eviction string `yaml:"eviction" default:"lru"`
+
+	// Custom eviction policy, it overrides Eviction. It is called once per
+	// shard
+	//
+	// Optional. Default is nil
+	Policy func() Policy `yaml:"-"`
+
+	// File to persist the keys to, a snapshot is written every
+	// SnapshotInterval and on Close and loaded by New. Expired keys are
+	// dropped when loading
+	//
+	// Optional. Default is "", no persistence
+	Path string `yaml:"path"`
+
+	// Time between two snapshots
+	//
+	// Default is 1 * time.Minute
+	SnapshotInterval time.Duration `yaml:"snapshotInterval" default:"1m"`
 
-// ConfigDefault is the default config
-var ConfigDefault = Config{
-	GCInterval: 10 * time.Second,
+	// Log every change to Path + ".log" between two snapshots, so changes
+	// survive a crash. The log is written without sync, it is lost with
+	// the page cache on power loss
+	//
+	// Default is false
+	AppendLog bool `yaml:"appendLog"`
+
+	// Called with every key and value evicted because of MaxEntries or
+	// MaxBytes, after the storage is unlocked
+	//
+	// Optional. Default is nil
+	OnEvict func(key string, val []byte) `yaml:"-"`
 }
 
+// ConfigDefault is the default config, built from the default tags of
+// Config
+var ConfigDefault = func() Config {
+	var cfg Config
+	storage.Defaults(&cfg)
+	return cfg
+}()
+
 // configDefault is a helper function to set default values
 func configDefault(config ...Config) Config {
 	// Return default config if nothing provided
@@ -29,5 +93,22 @@ func configDefault(config ...Config) Config {
 	if int(cfg.GCInterval.Seconds()) <= 0 {
 		cfg.GCInterval = ConfigDefault.GCInterval
 	}
+	if cfg.Shards <= 0 {
+		cfg.Shards = ConfigDefault.Shards
+	}
+	// Round up to a power of two for masking
+	for n := cfg.Shards; n&(n-1) != 0; n = cfg.Shards {
+		cfg.Shards = n + n&-n
+	}
+	// Every shard needs a share of the limits, a share of 0 is no limit
+	for cfg.Shards > 1 && (cfg.MaxEntries > 0 && cfg.MaxEntries < cfg.Shards || cfg.MaxBytes > 0 && cfg.MaxBytes < int64(cfg.Shards)) {
+		cfg.Shards /= 2
+	}
+	if cfg.SnapshotInterval <= 0 {
+		cfg.SnapshotInterval = ConfigDefault.SnapshotInterval
+	}
+	if cfg.Eviction == "" {
+		cfg.Eviction = ConfigDefault.Eviction
+	}
 	return cfg
 }
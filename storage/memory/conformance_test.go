@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/20326/flexbox/storage"
+	"github.com/20326/flexbox/storage/storagetest"
+)
+
+func Test_Storage_Memory_Conformance(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Storage {
+		return New()
+	})
+}
+
+func Test_Storage_Memory_Conformance_Sharded(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Storage {
+		return New(Config{Shards: 16})
+	})
+}
+
+func Test_Storage_Memory_Conformance_Persistent(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Storage {
+		return New(Config{
+			Path:      filepath.Join(t.TempDir(), "memory.snapshot"),
+			AppendLog: true,
+		})
+	})
+}
+
+func Test_Storage_Memory_Conformance_Namespace(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Storage {
+		return storage.Namespace(New(Config{Shards: 4}), "svc-a:")
+	})
+}
+
+// scanOnly hides every optional interface of the memory storage but Scanner
+type scanOnly struct {
+	storage.Storage
+	storage.Scanner
+}
+
+func Test_Storage_Memory_Conformance_Namespace_ScanOnly(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Storage {
+		s := New()
+		return storage.Namespace(scanOnly{s, s}, "svc-a:")
+	})
+}
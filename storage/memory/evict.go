@@ -0,0 +1,378 @@
+package memory
+
+import (
+	"container/heap"
+	"container/list"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// Eviction policies for Config.Eviction
+const (
+	// LRU evicts the least recently used key
+	LRU = "lru"
+	// LFU evicts the least frequently used key, the least recently used
+	// one among equals
+	LFU = "lfu"
+	// Random evicts a random key
+	Random = "random"
+)
+
+// Policy decides which key to evict when MaxEntries or MaxBytes is reached.
+// Every shard has its own Policy. The storage calls Add, Remove, Victim and
+// Reset with the write lock of the shard held and Access with its read lock
+// held, so Access may run concurrently with itself.
+type Policy interface {
+	// Add is called when key is stored, new or replaced
+	Add(key string)
+	// Access is called when key is read
+	Access(key string)
+	// Remove is called when key is deleted, expired or evicted
+	Remove(key string)
+	// Victim returns the next key to evict, ok is false if there is none
+	Victim() (key string, ok bool)
+	// Reset is called when the storage is reset
+	Reset()
+}
+
+// NewPolicy returns a new instance of the eviction policy name, LRU for
+// unknown names
+func NewPolicy(name string) Policy {
+	switch name {
+	case LFU:
+		return newLFU()
+	case Random:
+		return newRandom()
+	default:
+		return newLRU()
+	}
+}
+
+// Stats are the counters of a storage for metrics
+type Stats struct {
+	// Hits and Misses count the reads of existing and missing keys
+	Hits   uint64
+	Misses uint64
+	// Evictions counts the keys evicted because of MaxEntries or MaxBytes
+	Evictions uint64
+	// Entries and Bytes are the current number of keys and the size of
+	// keys and values, expired keys included until the gc removes them
+	Entries int
+	Bytes   int64
+}
+
+// Stats returns the current counters
+func (s *Storage) Stats() Stats {
+	var (
+		entries int
+		bytes   int64
+	)
+	for _, sh := range s.shards {
+		sh.mux.RLock()
+		entries += len(sh.db)
+		bytes += sh.bytes
+		sh.mux.RUnlock()
+	}
+	return Stats{
+		Hits:      atomic.LoadUint64(&s.hits),
+		Misses:    atomic.LoadUint64(&s.misses),
+		Evictions: atomic.LoadUint64(&s.evictions),
+		Entries:   entries,
+		Bytes:     bytes,
+	}
+}
+
+type evicted struct {
+	key  string
+	data []byte
+}
+
+// hit records a read, the caller must hold the lock of sh
+func (s *Storage) hit(sh *shard, key string, ok bool) {
+	if !ok {
+		atomic.AddUint64(&s.misses, 1)
+		return
+	}
+	atomic.AddUint64(&s.hits, 1)
+	if sh.policy != nil {
+		sh.policy.Access(key)
+	}
+}
+
+// put stores e at key in sh and evicts keys until sh is within its limits
+// again, the caller must hold the write lock of sh and pass the evicted
+// entries to notify after unlocking
+func (s *Storage) put(sh *shard, key string, e entry) []evicted {
+	// An entry larger than the shard is evicted right away, rather than
+	// after every other key
+	if sh.maxBytes > 0 && int64(len(key)+len(e.data)) > sh.maxBytes {
+		sh.remove(key)
+		atomic.AddUint64(&s.evictions, 1)
+		return []evicted{{key, e.data}}
+	}
+
+	old, replaced := sh.db[key]
+	if replaced {
+		sh.bytes -= int64(len(key) + len(old.data))
+	}
+	sh.db[key] = e
+	sh.bytes += int64(len(key) + len(e.data))
+	sh.log.set(key, e)
+
+	if sh.policy == nil {
+		return nil
+	}
+	// new keys are added after making room, so they are never their own
+	// victim
+	if replaced {
+		sh.policy.Add(key)
+	} else {
+		defer sh.policy.Add(key)
+	}
+
+	var out []evicted
+	for sh.maxEntries > 0 && len(sh.db) > sh.maxEntries || sh.maxBytes > 0 && sh.bytes > sh.maxBytes {
+		victim, ok := sh.policy.Victim()
+		if !ok {
+			break
+		}
+		v, ok := sh.db[victim]
+		if !ok {
+			sh.policy.Remove(victim)
+			continue
+		}
+		sh.remove(victim)
+		out = append(out, evicted{victim, v.data})
+	}
+	if len(out) > 0 {
+		atomic.AddUint64(&s.evictions, uint64(len(out)))
+	}
+	return out
+}
+
+// remove deletes key, the caller must hold the write lock
+func (sh *shard) remove(key string) {
+	old, ok := sh.db[key]
+	if !ok {
+		return
+	}
+	delete(sh.db, key)
+	sh.bytes -= int64(len(key) + len(old.data))
+	sh.log.delete(key)
+	if sh.policy != nil {
+		sh.policy.Remove(key)
+	}
+}
+
+// notify calls OnEvict for the evicted entries, without the lock held
+func (s *Storage) notify(out []evicted) {
+	if s.onEvict == nil {
+		return
+	}
+	for _, e := range out {
+		s.onEvict(e.key, e.data)
+	}
+}
+
+// lru keeps keys in a list, most recently used first
+type lru struct {
+	mux   sync.Mutex
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+func newLRU() *lru {
+	return &lru{order: list.New(), keys: make(map[string]*list.Element)}
+}
+
+func (p *lru) Add(key string) {
+	p.mux.Lock()
+	if el, ok := p.keys[key]; ok {
+		p.order.MoveToFront(el)
+	} else {
+		p.keys[key] = p.order.PushFront(key)
+	}
+	p.mux.Unlock()
+}
+
+func (p *lru) Access(key string) {
+	p.mux.Lock()
+	if el, ok := p.keys[key]; ok {
+		p.order.MoveToFront(el)
+	}
+	p.mux.Unlock()
+}
+
+func (p *lru) Remove(key string) {
+	p.mux.Lock()
+	if el, ok := p.keys[key]; ok {
+		p.order.Remove(el)
+		delete(p.keys, key)
+	}
+	p.mux.Unlock()
+}
+
+func (p *lru) Victim() (string, bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if el := p.order.Back(); el != nil {
+		return el.Value.(string), true
+	}
+	return "", false
+}
+
+func (p *lru) Reset() {
+	p.mux.Lock()
+	p.order.Init()
+	p.keys = make(map[string]*list.Element)
+	p.mux.Unlock()
+}
+
+// lfu keeps keys in a min-heap of use counts, ties are broken by the last
+// use
+type lfu struct {
+	mux   sync.Mutex
+	tick  uint64
+	items lfuHeap
+	keys  map[string]*lfuItem
+}
+
+type lfuItem struct {
+	key   string
+	count uint64
+	tick  uint64
+	index int
+}
+
+type lfuHeap []*lfuItem
+
+func (h lfuHeap) Len() int { return len(h) }
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].tick < h[j].tick
+}
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *lfuHeap) Push(x interface{}) {
+	item := x.(*lfuItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+func newLFU() *lfu {
+	return &lfu{keys: make(map[string]*lfuItem)}
+}
+
+func (p *lfu) Add(key string) {
+	p.mux.Lock()
+	p.tick++
+	if item, ok := p.keys[key]; ok {
+		item.count++
+		item.tick = p.tick
+		heap.Fix(&p.items, item.index)
+	} else {
+		item := &lfuItem{key: key, count: 1, tick: p.tick}
+		heap.Push(&p.items, item)
+		p.keys[key] = item
+	}
+	p.mux.Unlock()
+}
+
+func (p *lfu) Access(key string) {
+	p.mux.Lock()
+	if item, ok := p.keys[key]; ok {
+		p.tick++
+		item.count++
+		item.tick = p.tick
+		heap.Fix(&p.items, item.index)
+	}
+	p.mux.Unlock()
+}
+
+func (p *lfu) Remove(key string) {
+	p.mux.Lock()
+	if item, ok := p.keys[key]; ok {
+		heap.Remove(&p.items, item.index)
+		delete(p.keys, key)
+	}
+	p.mux.Unlock()
+}
+
+func (p *lfu) Victim() (string, bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if len(p.items) == 0 {
+		return "", false
+	}
+	return p.items[0].key, true
+}
+
+func (p *lfu) Reset() {
+	p.mux.Lock()
+	p.items = nil
+	p.keys = make(map[string]*lfuItem)
+	p.mux.Unlock()
+}
+
+// random keeps keys in a slice to pick a victim in O(1)
+type random struct {
+	mux  sync.Mutex
+	list []string
+	keys map[string]int
+}
+
+func newRandom() *random {
+	return &random{keys: make(map[string]int)}
+}
+
+func (p *random) Add(key string) {
+	p.mux.Lock()
+	if _, ok := p.keys[key]; !ok {
+		p.keys[key] = len(p.list)
+		p.list = append(p.list, key)
+	}
+	p.mux.Unlock()
+}
+
+func (p *random) Access(string) {}
+
+func (p *random) Remove(key string) {
+	p.mux.Lock()
+	if i, ok := p.keys[key]; ok {
+		last := p.list[len(p.list)-1]
+		p.list[i] = last
+		p.keys[last] = i
+		p.list = p.list[:len(p.list)-1]
+		delete(p.keys, key)
+	}
+	p.mux.Unlock()
+}
+
+func (p *random) Victim() (string, bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if len(p.list) == 0 {
+		return "", false
+	}
+	return p.list[rand.Intn(len(p.list))], true
+}
+
+func (p *random) Reset() {
+	p.mux.Lock()
+	p.list = nil
+	p.keys = make(map[string]int)
+	p.mux.Unlock()
+}
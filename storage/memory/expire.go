@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"time"
+)
+
+// TTL returns the time left before key expires with millisecond precision,
+// ok is false if key does not exist
+func (s *Storage) TTL(key string) (time.Duration, bool, error) {
+	c := newClock()
+	sh := s.shard(key)
+	sh.mux.RLock()
+	v, ok := sh.live(key, &c)
+	sh.mux.RUnlock()
+	if !ok {
+		return 0, false, nil
+	}
+	if v.expiry == 0 {
+		return 0, true, nil
+	}
+	return time.Duration(v.expiry-c.ms()) * time.Millisecond, true, nil
+}
+
+// Touch sets the expiration of key to exp from now, 0 means no expiration
+func (s *Storage) Touch(key string, exp time.Duration) error {
+	c := newClock()
+	expire := c.expiry(exp)
+
+	sh := s.shard(key)
+	sh.mux.Lock()
+	if v, ok := sh.live(key, &c); ok {
+		v.expiry = expire
+		sh.db[key] = v
+		sh.log.set(key, v)
+	}
+	sh.mux.Unlock()
+	return nil
+}
@@ -3,5 +3,9 @@ module storage/memory
 go 1.18
 
 require (
+	github.com/20326/flexbox v0.0.0
 	github.com/gofiber/utils v1.0.1
+	go.uber.org/goleak v1.2.1
 )
+
+replace github.com/20326/flexbox => ../..
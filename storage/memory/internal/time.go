@@ -7,26 +7,59 @@ import (
 )
 
 var (
-	timestampTimer sync.Once
+	timestampMux     sync.Mutex
+	timestampRefs    int
+	timestampStop    chan struct{}
+	timestampStopped chan struct{}
 	// Timestamp please start the timer function before you use this value
 	// please load the value with atomic `atomic.LoadUint32(&utils.Timestamp)`
 	Timestamp uint32
 )
 
 // StartTimeStampUpdater starts a concurrent function which stores the timestamp to an atomic value per second,
-// which is much better for performance than determining it at runtime each time
+// which is much better for performance than determining it at runtime each time.
+// Every call must be matched by a call to StopTimeStampUpdater, the function runs until the last one
 func StartTimeStampUpdater() {
-	timestampTimer.Do(func() {
-		// set initial value
-		atomic.StoreUint32(&Timestamp, uint32(time.Now().Unix()))
-		go func(sleep time.Duration) {
-			ticker := time.NewTicker(sleep)
-			defer ticker.Stop()
-
-			for t := range ticker.C {
+	timestampMux.Lock()
+	defer timestampMux.Unlock()
+
+	timestampRefs++
+	if timestampRefs > 1 {
+		return
+	}
+
+	// set initial value
+	atomic.StoreUint32(&Timestamp, uint32(time.Now().Unix()))
+	timestampStop = make(chan struct{})
+	timestampStopped = make(chan struct{})
+	go func(sleep time.Duration, stop, stopped chan struct{}) {
+		defer close(stopped)
+		ticker := time.NewTicker(sleep)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case t := <-ticker.C:
 				// update timestamp
 				atomic.StoreUint32(&Timestamp, uint32(t.Unix()))
 			}
-		}(1 * time.Second) // duration
-	})
+		}
+	}(1*time.Second, timestampStop, timestampStopped) // duration
+}
+
+// StopTimeStampUpdater releases a call to StartTimeStampUpdater, the last one stops the function and waits for it
+func StopTimeStampUpdater() {
+	timestampMux.Lock()
+	defer timestampMux.Unlock()
+
+	if timestampRefs == 0 {
+		return
+	}
+	timestampRefs--
+	if timestampRefs == 0 {
+		close(timestampStop)
+		<-timestampStopped
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gofiber/utils"
+	"go.uber.org/goleak"
 )
 
 func checkTimeStamp(t testing.TB, expectedCurrent, actualCurrent uint32) {
@@ -17,6 +18,7 @@ func Test_TimeStampUpdater(t *testing.T) {
 	t.Parallel()
 
 	StartTimeStampUpdater()
+	defer StopTimeStampUpdater()
 
 	now := uint32(time.Now().Unix())
 	checkTimeStamp(t, now, atomic.LoadUint32(&Timestamp))
@@ -28,8 +30,20 @@ func Test_TimeStampUpdater(t *testing.T) {
 	checkTimeStamp(t, now+2, atomic.LoadUint32(&Timestamp))
 }
 
+func Test_TimeStampUpdater_Stop(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	StartTimeStampUpdater()
+	StartTimeStampUpdater()
+	StopTimeStampUpdater()
+	StopTimeStampUpdater()
+	// unmatched calls are ignored
+	StopTimeStampUpdater()
+}
+
 func Benchmark_CalculateTimestamp(b *testing.B) {
 	StartTimeStampUpdater()
+	defer StopTimeStampUpdater()
 
 	var res uint32
 	b.Run("fiber", func(b *testing.B) {
@@ -1,8 +1,8 @@
 package memory
 
 import (
+	"context"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"storage/memory/internal"
@@ -10,32 +10,77 @@ import (
 
 // Storage interface that is implemented by storage providers
 type Storage struct {
-	mux        sync.RWMutex
-	db         map[string]entry
+	// accessed atomically, first for 64-bit alignment
+	hits      uint64
+	misses    uint64
+	evictions uint64
+
+	shards     []*shard
+	mask       uint32
 	gcInterval time.Duration
 	done       chan struct{}
+	wg         sync.WaitGroup
+	closeOnce  sync.Once
+	closeErr   error
+	onEvict    func(key string, val []byte)
+
+	// persistence, path is empty unless Config.Path is set
+	path    string
+	snapMux sync.Mutex
+	log     *appendLog
 }
 
 type entry struct {
 	data []byte
-	// max value is 4294967295 -> Sun Feb 07 2106 06:28:15 GMT+0000
-	expiry uint32
+	// unix milliseconds, 0 means no expiration
+	expiry int64
 }
 
-// New creates a new memory storage
+// New creates a new memory storage, it panics if the snapshot or the log
+// at Config.Path cannot be loaded
 func New(config ...Config) *Storage {
 	// Set default config
 	cfg := configDefault(config...)
 
 	// Create storage
 	store := &Storage{
-		db:         make(map[string]entry),
+		shards:     make([]*shard, cfg.Shards),
+		mask:       uint32(cfg.Shards - 1),
 		gcInterval: cfg.GCInterval,
 		done:       make(chan struct{}),
+		onEvict:    cfg.OnEvict,
+		path:       cfg.Path,
+	}
+
+	// Split the limits so they add up to the configured ones
+	for i := range store.shards {
+		sh := &shard{
+			db:         make(map[string]entry),
+			maxEntries: int(share(int64(cfg.MaxEntries), cfg.Shards, i)),
+			maxBytes:   share(cfg.MaxBytes, cfg.Shards, i),
+		}
+		if cfg.MaxEntries > 0 || cfg.MaxBytes > 0 {
+			if cfg.Policy != nil {
+				sh.policy = cfg.Policy()
+			} else {
+				sh.policy = NewPolicy(cfg.Eviction)
+			}
+		}
+		store.shards[i] = sh
 	}
 
-	// Start garbage collector
 	internal.StartTimeStampUpdater()
+	if cfg.Path != "" {
+		if err := store.restore(cfg); err != nil {
+			internal.StopTimeStampUpdater()
+			panic(err)
+		}
+		store.wg.Add(1)
+		go store.snapshotter(cfg.SnapshotInterval)
+	}
+
+	// Start garbage collector
+	store.wg.Add(1)
 	go store.gc()
 
 	return store
@@ -46,10 +91,13 @@ func (s *Storage) Get(key string) ([]byte, error) {
 	if len(key) <= 0 {
 		return nil, nil
 	}
-	s.mux.RLock()
-	v, ok := s.db[key]
-	s.mux.RUnlock()
-	if !ok || v.expiry != 0 && v.expiry <= atomic.LoadUint32(&internal.Timestamp) {
+	sh := s.shard(key)
+	sh.mux.RLock()
+	c := newClock()
+	v, ok := sh.live(key, &c)
+	s.hit(sh, key, ok)
+	sh.mux.RUnlock()
+	if !ok {
 		return nil, nil
 	}
 
@@ -63,15 +111,13 @@ func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
 		return nil
 	}
 
-	var expire uint32
-	if exp != 0 {
-		expire = uint32(exp.Seconds()) + atomic.LoadUint32(&internal.Timestamp)
-	}
-
-	e := entry{val, expire}
-	s.mux.Lock()
-	s.db[key] = e
-	s.mux.Unlock()
+	c := newClock()
+	e := entry{val, c.expiry(exp)}
+	sh := s.shard(key)
+	sh.mux.Lock()
+	evicted := s.put(sh, key, e)
+	sh.mux.Unlock()
+	s.notify(evicted)
 	return nil
 }
 
@@ -81,28 +127,100 @@ func (s *Storage) Delete(key string) error {
 	if len(key) <= 0 {
 		return nil
 	}
-	s.mux.Lock()
-	delete(s.db, key)
-	s.mux.Unlock()
+	sh := s.shard(key)
+	sh.mux.Lock()
+	sh.remove(key)
+	sh.mux.Unlock()
 	return nil
 }
 
 // Reset all keys
 func (s *Storage) Reset() error {
-	ndb := make(map[string]entry)
-	s.mux.Lock()
-	s.db = ndb
-	s.mux.Unlock()
+	// Lock every shard, so the reset is a single record in the log
+	for _, sh := range s.shards {
+		sh.mux.Lock()
+	}
+	for _, sh := range s.shards {
+		sh.clear()
+	}
+	s.log.reset()
+	for _, sh := range s.shards {
+		sh.mux.Unlock()
+	}
 	return nil
 }
 
-// Close the memory storage
+// Close the memory storage and write a last snapshot if Config.Path is
+// set, it waits for a running gc or snapshot. Calling it more than once
+// returns the first result
 func (s *Storage) Close() error {
-	s.done <- struct{}{}
-	return nil
+	return s.Shutdown(context.Background())
 }
 
+// Shutdown is Close with a deadline, it returns ctx.Err() if ctx is done
+// before the running gc or snapshot. The last snapshot is written anyway.
+// Calling Close or Shutdown more than once returns the first result
+func (s *Storage) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		stopped := make(chan struct{})
+		go func() {
+			s.wg.Wait()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			s.closeErr = ctx.Err()
+		}
+
+		if err := s.Snapshot(); s.closeErr == nil {
+			s.closeErr = err
+		}
+		if err := s.log.close(); s.closeErr == nil {
+			s.closeErr = err
+		}
+		internal.StopTimeStampUpdater()
+	})
+	return s.closeErr
+}
+
+// GetWithContext gets value by key, it fails if ctx is already done
+func (s *Storage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Get(key)
+}
+
+// SetWithContext sets key with value, it fails if ctx is already done
+func (s *Storage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Set(key, val, exp)
+}
+
+// DeleteWithContext deletes key by key, it fails if ctx is already done
+func (s *Storage) DeleteWithContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Delete(key)
+}
+
+// ResetWithContext resets all keys, it fails if ctx is already done
+func (s *Storage) ResetWithContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Reset()
+}
+
+// gc deletes expired keys one shard after another, so only one shard is
+// locked at a time
 func (s *Storage) gc() {
+	defer s.wg.Done()
 	ticker := time.NewTicker(s.gcInterval)
 	defer ticker.Stop()
 	var expired []string
@@ -112,32 +230,35 @@ func (s *Storage) gc() {
 		case <-s.done:
 			return
 		case <-ticker.C:
-			ts := atomic.LoadUint32(&internal.Timestamp)
-			expired = expired[:0]
-			s.mux.RLock()
-			for id, v := range s.db {
-				if v.expiry != 0 && v.expiry < ts {
-					expired = append(expired, id)
-				}
-			}
-			s.mux.RUnlock()
-			s.mux.Lock()
-			// Double-checked locking.
-			// We might have replaced the item in the meantime.
-			for i := range expired {
-				v := s.db[expired[i]]
-				if v.expiry != 0 && v.expiry <= ts {
-					delete(s.db, expired[i])
+			now := time.Now().UnixMilli()
+			for _, sh := range s.shards {
+				select {
+				case <-s.done:
+					return
+				default:
 				}
+				expired = sh.gc(now, expired)
 			}
-			s.mux.Unlock()
 		}
 	}
 }
 
-// Return database client
+// Return database client, a copy of all keys if the storage is sharded
 func (s *Storage) Conn() map[string]entry {
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-	return s.db
+	if len(s.shards) == 1 {
+		sh := s.shards[0]
+		sh.mux.RLock()
+		defer sh.mux.RUnlock()
+		return sh.db
+	}
+
+	db := make(map[string]entry)
+	for _, sh := range s.shards {
+		sh.mux.RLock()
+		for key, v := range sh.db {
+			db[key] = v
+		}
+		sh.mux.RUnlock()
+	}
+	return db
 }
@@ -1,119 +1,396 @@
 package memory
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/20326/flexbox/storage"
 	"github.com/gofiber/utils"
+	"go.uber.org/goleak"
+
+	"storage/memory/internal"
 )
 
 var testStore = New()
 
-func Test_Storage_Memory_Set(t *testing.T) {
+// TestMain fails the tests if a storage leaks a goroutine
+func TestMain(m *testing.M) {
+	code := m.Run()
+	_ = testStore.Close()
+	if code == 0 {
+		if err := goleak.Find(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+func Test_Storage_Memory_WithContext(t *testing.T) {
 	var (
 		key = "john"
 		val = []byte("doe")
+		ctx = context.Background()
 	)
 
-	err := testStore.Set(key, val, 0)
+	err := testStore.SetWithContext(ctx, key, val, 0)
+	utils.AssertEqual(t, nil, err)
+
+	result, err := testStore.GetWithContext(ctx, key)
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, val, result)
+
+	err = testStore.DeleteWithContext(ctx, key)
+	utils.AssertEqual(t, nil, err)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = testStore.GetWithContext(canceled, key)
+	utils.AssertEqual(t, context.Canceled, err)
+
+	err = testStore.SetWithContext(canceled, key, val, 0)
+	utils.AssertEqual(t, context.Canceled, err)
+
+	err = testStore.ResetWithContext(canceled)
+	utils.AssertEqual(t, context.Canceled, err)
 }
 
-func Test_Storage_Memory_Set_Override(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
+func Test_Storage_Memory_Subsecond_Expiration(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	utils.AssertEqual(t, nil, store.Set("short", []byte("doe"), 300*time.Millisecond))
+	utils.AssertEqual(t, nil, store.Set("tiny", []byte("doe"), time.Nanosecond))
+	utils.AssertEqual(t, nil, store.Set("long", []byte("doe"), 1900*time.Millisecond))
+	utils.AssertEqual(t, nil, store.Set("touched", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, store.Touch("touched", 600*time.Millisecond))
 
-	err := testStore.Set(key, val, 0)
+	ttl, ok, err := store.TTL("long")
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, ok)
+	utils.AssertEqual(t, true, ttl > 1800*time.Millisecond && ttl <= 1900*time.Millisecond)
 
-	err = testStore.Set(key, val, 0)
+	result, err := store.Get("short")
 	utils.AssertEqual(t, nil, err)
-}
+	utils.AssertEqual(t, []byte("doe"), result)
 
-func Test_Storage_Memory_Get(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
+	time.Sleep(400 * time.Millisecond)
 
-	err := testStore.Set(key, val, 0)
+	for _, key := range []string{"short", "tiny"} {
+		result, err = store.Get(key)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, len(result) == 0)
+	}
+	_, ok, err = store.TTL("touched")
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, ok)
+
+	time.Sleep(1300 * time.Millisecond)
 
-	result, err := testStore.Get(key)
+	result, err = store.Get("long")
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, val, result)
-}
+	utils.AssertEqual(t, []byte("doe"), result)
+	_, ok, err = store.TTL("touched")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, false, ok)
 
-func Test_Storage_Memory_Set_Expiration(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-		exp = 1 * time.Second
-	)
+	time.Sleep(300 * time.Millisecond)
 
-	err := testStore.Set(key, val, exp)
+	result, err = store.Get("long")
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, len(result) == 0)
+}
 
-	time.Sleep(1100 * time.Millisecond)
+func Test_Storage_Memory_Eviction_LRU(t *testing.T) {
+	var evicted []string
+	store := New(Config{
+		MaxEntries: 2,
+		OnEvict: func(key string, val []byte) {
+			evicted = append(evicted, key)
+		},
+	})
+	defer store.Close()
+
+	utils.AssertEqual(t, nil, store.Set("a", []byte("1"), 0))
+	utils.AssertEqual(t, nil, store.Set("b", []byte("2"), 0))
+	_, _ = store.Get("a")
+	utils.AssertEqual(t, nil, store.Set("c", []byte("3"), 0))
+
+	utils.AssertEqual(t, []string{"b"}, evicted)
+	result, _ := store.Get("b")
+	utils.AssertEqual(t, true, result == nil)
+	result, _ = store.Get("a")
+	utils.AssertEqual(t, []byte("1"), result)
+
+	stats := store.Stats()
+	utils.AssertEqual(t, uint64(2), stats.Hits)
+	utils.AssertEqual(t, uint64(1), stats.Misses)
+	utils.AssertEqual(t, uint64(1), stats.Evictions)
+	utils.AssertEqual(t, 2, stats.Entries)
+	utils.AssertEqual(t, int64(4), stats.Bytes)
 }
 
-func Test_Storage_Memory_Get_Expired(t *testing.T) {
-	var (
-		key = "john"
-	)
+func Test_Storage_Memory_Eviction_LFU(t *testing.T) {
+	store := New(Config{
+		MaxEntries: 2,
+		Eviction:   LFU,
+	})
+	defer store.Close()
+
+	utils.AssertEqual(t, nil, store.Set("a", []byte("1"), 0))
+	utils.AssertEqual(t, nil, store.Set("b", []byte("2"), 0))
+	_, _ = store.Get("a")
+	_, _ = store.Get("a")
+	_, _ = store.Get("b")
+	utils.AssertEqual(t, nil, store.Set("c", []byte("3"), 0))
+
+	result, _ := store.Get("b")
+	utils.AssertEqual(t, true, result == nil)
+	result, _ = store.Get("a")
+	utils.AssertEqual(t, []byte("1"), result)
+	result, _ = store.Get("c")
+	utils.AssertEqual(t, []byte("3"), result)
+}
+
+func Test_Storage_Memory_Eviction_MaxBytes(t *testing.T) {
+	store := New(Config{
+		MaxBytes: 10,
+		Eviction: Random,
+	})
+	defer store.Close()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		utils.AssertEqual(t, nil, store.Set(key, []byte("1234"), 0))
+	}
+
+	stats := store.Stats()
+	utils.AssertEqual(t, 2, stats.Entries)
+	utils.AssertEqual(t, int64(10), stats.Bytes)
+	utils.AssertEqual(t, uint64(2), stats.Evictions)
 
-	result, err := testStore.Get(key)
+	// A value larger than MaxBytes is evicted alone
+	utils.AssertEqual(t, nil, store.Set("big", []byte("12345678910"), 0))
+	result, err := store.Get("big")
 	utils.AssertEqual(t, nil, err)
 	utils.AssertEqual(t, true, len(result) == 0)
+	stats = store.Stats()
+	utils.AssertEqual(t, 2, stats.Entries)
+	utils.AssertEqual(t, uint64(3), stats.Evictions)
+
+	utils.AssertEqual(t, nil, store.Reset())
+	utils.AssertEqual(t, int64(0), store.Stats().Bytes)
 }
 
-func Test_Storage_Memory_Get_NotExist(t *testing.T) {
+func Test_Storage_Memory_Shards(t *testing.T) {
+	store := New(Config{
+		Shards:     10,
+		MaxEntries: 1024,
+	})
+	defer store.Close()
+
+	utils.AssertEqual(t, 16, len(store.shards))
+
+	// The shares of the limits add up to them
+	for _, cfg := range []Config{{Shards: 16, MaxEntries: 1000, MaxBytes: 1 << 20}, {Shards: 16, MaxEntries: 6}} {
+		limited := New(cfg)
+		var entries int
+		var bytes int64
+		for _, sh := range limited.shards {
+			entries += sh.maxEntries
+			bytes += sh.maxBytes
+		}
+		utils.AssertEqual(t, cfg.MaxEntries, entries)
+		utils.AssertEqual(t, cfg.MaxBytes, bytes)
+		utils.AssertEqual(t, nil, limited.Close())
+	}
+	utils.AssertEqual(t, 4, configDefault(Config{Shards: 16, MaxEntries: 6}).Shards)
+
+	keys := make([]string, 32)
+	entries := make(map[string]storage.Entry, len(keys))
+	for i := range keys {
+		keys[i] = utils.UUID()
+		entries[keys[i]] = storage.Entry{Value: []byte("doe")}
+	}
+	utils.AssertEqual(t, nil, store.SetMany(entries))
+
+	values, err := store.GetMany(keys)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, len(keys), len(values))
+	utils.AssertEqual(t, len(keys), len(store.Conn()))
 
-	result, err := testStore.Get("notexist")
+	var scanned []string
+	err = store.Scan(context.Background(), "", 0, func(page []string) error {
+		scanned = append(scanned, page...)
+		return nil
+	})
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
+	sort.Strings(keys)
+	utils.AssertEqual(t, keys, scanned)
+
+	utils.AssertEqual(t, nil, store.DeleteMany(keys[:16]))
+	utils.AssertEqual(t, 16, store.Stats().Entries)
+
+	utils.AssertEqual(t, nil, store.Reset())
+	utils.AssertEqual(t, 0, store.Stats().Entries)
 }
 
-func Test_Storage_Memory_Delete(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
+func Test_Storage_Memory_Shards_GC(t *testing.T) {
+	store := New(Config{
+		GCInterval: time.Second,
+		Shards:     4,
+	})
+	defer store.Close()
+
+	for i := 0; i < 100; i++ {
+		utils.AssertEqual(t, nil, store.Set(utils.UUID(), []byte("doe"), time.Second))
+	}
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+
+	time.Sleep(3 * time.Second)
+	utils.AssertEqual(t, 1, store.Stats().Entries)
+}
+
+func Test_Storage_Memory_Snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.snapshot")
+
+	store := New(Config{Path: path, Shards: 4})
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, store.Set("jane", []byte("doe"), time.Hour))
+	utils.AssertEqual(t, nil, store.Set("temp", []byte("doe"), time.Second))
+	utils.AssertEqual(t, nil, store.Close())
+
+	time.Sleep(2 * time.Second)
+
+	store = New(Config{Path: path})
+	defer store.Close()
 
-	err := testStore.Set(key, val, 0)
+	result, err := store.Get("john")
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), result)
 
-	err = testStore.Delete(key)
+	ttl, ok, err := store.TTL("jane")
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, ok)
+	utils.AssertEqual(t, true, ttl > 55*time.Minute)
 
-	result, err := testStore.Get(key)
+	_, ok, err = store.TTL("temp")
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
+	utils.AssertEqual(t, false, ok)
+	utils.AssertEqual(t, 2, store.Stats().Entries)
+
+	utils.AssertEqual(t, nil, store.Snapshot())
+	matches, err := filepath.Glob(path + ".tmp*")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 0, len(matches))
 }
 
-func Test_Storage_Memory_Reset(t *testing.T) {
-	var (
-		val = []byte("doe")
-	)
+func Test_Storage_Memory_AppendLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.snapshot")
 
-	err := testStore.Set("john1", val, 0)
+	store := New(Config{Path: path, AppendLog: true})
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, store.Snapshot())
+	utils.AssertEqual(t, nil, store.Set("jane", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, store.Delete("john"))
+	_, err := store.Increment("hits", 2, 0)
 	utils.AssertEqual(t, nil, err)
 
-	err = testStore.Set("john2", val, 0)
+	// Simulate a crash and tear the last record of the log
+	crash(store)
+	f, err := os.OpenFile(path+".log", os.O_WRONLY|os.O_APPEND, 0o600)
 	utils.AssertEqual(t, nil, err)
-
-	err = testStore.Reset()
+	_, err = f.Write([]byte{opSet, 4, 'h'})
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, nil, f.Close())
+
+	store = New(Config{Path: path, AppendLog: true})
 
-	result, err := testStore.Get("john1")
+	result, err := store.Get("john")
 	utils.AssertEqual(t, nil, err)
 	utils.AssertEqual(t, true, len(result) == 0)
 
-	result, err = testStore.Get("john2")
+	result, err = store.Get("jane")
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
+	utils.AssertEqual(t, []byte("doe"), result)
+
+	result, err = store.Get("hits")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("2"), result)
+
+	utils.AssertEqual(t, nil, store.Reset())
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+	crash(store)
+
+	store = New(Config{Path: path})
+	defer store.Close()
+	utils.AssertEqual(t, 1, store.Stats().Entries)
+}
+
+// crash stops s without writing a last snapshot
+func crash(s *Storage) {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+		_ = s.log.close()
+		internal.StopTimeStampUpdater()
+	})
+}
+
+func Test_Storage_Memory_Snapshot_Version1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.snapshot")
+	expiry := time.Now().Add(time.Hour).Unix()
+
+	// Version 1 stored the expiry in seconds
+	data := append([]byte(snapshotMagic), 1)
+	data = appendRecord(data, opSet, "john", entry{[]byte("doe"), expiry})
+	utils.AssertEqual(t, nil, os.WriteFile(path, data, 0o600))
+
+	store := New(Config{Path: path})
+	defer store.Close()
+
+	ttl, ok, err := store.TTL("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, ok)
+	utils.AssertEqual(t, true, ttl > 59*time.Minute && ttl <= time.Hour)
+}
+
+func Test_Storage_Memory_Snapshot_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.snapshot")
+	utils.AssertEqual(t, nil, os.WriteFile(path, []byte("not a snapshot"), 0o600))
+
+	defer func() {
+		err, _ := recover().(error)
+		utils.AssertEqual(t, true, errors.Is(err, errCorrupt))
+	}()
+	New(Config{Path: path})
+}
+
+func Test_Storage_Memory_Shutdown(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	for i := 0; i < 10; i++ {
+		store := New(Config{
+			Path:      filepath.Join(t.TempDir(), "memory.snapshot"),
+			AppendLog: true,
+		})
+		utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+		utils.AssertEqual(t, nil, store.Shutdown(context.Background()))
+		utils.AssertEqual(t, nil, store.Close())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store := New()
+	err := store.Shutdown(ctx)
+	utils.AssertEqual(t, true, err == nil || err == context.Canceled)
+	utils.AssertEqual(t, err, store.Close())
 }
 
 func Test_Storage_Memory_Close(t *testing.T) {
@@ -124,7 +401,6 @@ func Test_Storage_Memory_Conn(t *testing.T) {
 	utils.AssertEqual(t, true, testStore.Conn() != nil)
 }
 
-
 // go test -v -run=^$ -bench=Benchmark_Storage_Memory -benchmem -count=4
 func Benchmark_Storage_Memory(b *testing.B) {
 	keyLength := 1000
@@ -151,4 +427,19 @@ func Benchmark_Storage_Memory(b *testing.B) {
 			}
 		}
 	})
+
+	for _, shards := range []int{1, 16} {
+		b.Run(fmt.Sprintf("fiber_memory_parallel_shards_%d", shards), func(b *testing.B) {
+			d := New(Config{Shards: shards})
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					key := keys[i%keyLength]
+					d.Set(key, value, ttl)
+					_, _ = d.Get(key)
+				}
+			})
+		})
+	}
 }
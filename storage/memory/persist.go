@@ -0,0 +1,423 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File headers, followed by the format version
+const (
+	snapshotMagic = "FBMS"
+	logMagic      = "FBML"
+)
+
+// formatVersion 2 stores expiries in unix milliseconds, version 1 stored
+// them in unix seconds and is still read
+const formatVersion byte = 2
+
+// Record operations of snapshots and logs
+const (
+	opSet    byte = 's'
+	opDelete byte = 'd'
+	opReset  byte = 'r'
+)
+
+// maxRecordSize guards against allocating huge buffers for corrupt lengths
+const maxRecordSize = 1 << 30
+
+// errCorrupt is returned by New when a snapshot or log cannot be decoded
+var errCorrupt = errors.New("memory: corrupt snapshot")
+
+// Snapshot writes all unexpired keys to Config.Path. The file is written
+// to a temporary file first and renamed, so a crash never leaves a partial
+// snapshot behind. With Config.AppendLog the log is rotated before and
+// dropped after the snapshot.
+func (s *Storage) Snapshot() error {
+	if s.path == "" {
+		return nil
+	}
+	s.snapMux.Lock()
+	defer s.snapMux.Unlock()
+
+	if err := s.log.rotate(); err != nil {
+		return err
+	}
+	if err := s.writeSnapshot(); err != nil {
+		return err
+	}
+	return s.log.dropOld()
+}
+
+// writeSnapshot copies the entries one shard at a time and writes them
+// without holding any lock
+func (s *Storage) writeSnapshot() error {
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	_, _ = w.WriteString(snapshotMagic)
+	_ = w.WriteByte(formatVersion)
+
+	type item struct {
+		key string
+		e   entry
+	}
+	var (
+		items []item
+		buf   []byte
+	)
+	now := time.Now().UnixMilli()
+	for _, sh := range s.shards {
+		items = items[:0]
+		sh.mux.RLock()
+		for key, v := range sh.db {
+			if v.expiry == 0 || v.expiry > now {
+				items = append(items, item{key, v})
+			}
+		}
+		sh.mux.RUnlock()
+
+		for _, it := range items {
+			buf = appendRecord(buf[:0], opSet, it.key, it.e)
+			if _, err = w.Write(buf); err != nil {
+				_ = f.Close()
+				return err
+			}
+		}
+	}
+
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// snapshotter writes a snapshot every interval until the storage is
+// closed. A failed snapshot is retried on the next tick, Close writes a
+// last one and reports its error.
+func (s *Storage) snapshotter(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			_ = s.Snapshot()
+		}
+	}
+}
+
+// restore loads the snapshot and the logs at cfg.Path and opens a new log
+// if cfg.AppendLog is set. Replayed logs are compacted into a snapshot
+// right away, so a torn record at their end is never appended to.
+func (s *Storage) restore(cfg Config) error {
+	replayed, err := s.load()
+	if err != nil {
+		return err
+	}
+	if replayed {
+		if err = s.writeSnapshot(); err != nil {
+			return err
+		}
+		for _, path := range []string{s.path + ".log.old", s.path + ".log"} {
+			if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	if cfg.AppendLog {
+		if s.log, err = openLog(s.path + ".log"); err != nil {
+			return err
+		}
+		for _, sh := range s.shards {
+			sh.log = s.log
+		}
+	}
+	return nil
+}
+
+// load restores the snapshot and replays the logs, it reports whether a
+// log was replayed. It must be called before the storage is shared.
+func (s *Storage) load() (bool, error) {
+	now := time.Now().UnixMilli()
+	apply := func(op byte, key string, e entry) {
+		switch op {
+		case opSet:
+			sh := s.shard(key)
+			if e.expiry != 0 && e.expiry <= now {
+				sh.remove(key)
+			} else {
+				s.put(sh, key, e)
+			}
+		case opDelete:
+			s.shard(key).remove(key)
+		case opReset:
+			for _, sh := range s.shards {
+				sh.clear()
+			}
+		}
+	}
+
+	if _, err := readFile(s.path, snapshotMagic, false, apply); err != nil {
+		return false, err
+	}
+	var replayed bool
+	for _, path := range []string{s.path + ".log.old", s.path + ".log"} {
+		ok, err := readFile(path, logMagic, true, apply)
+		if err != nil {
+			return false, err
+		}
+		replayed = replayed || ok
+	}
+	return replayed, nil
+}
+
+// readFile calls apply for every record of the file at path, a missing
+// file is not an error. With torn, a truncated last record is ignored,
+// logs are appended without a final sync and may end in one.
+func readFile(path, magic string, torn bool, apply func(op byte, key string, e entry)) (bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, len(magic)+1)
+	if _, err = io.ReadFull(r, header); err != nil {
+		if torn && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+			return true, nil
+		}
+		return false, fmt.Errorf("%w: %s", errCorrupt, path)
+	}
+	var scale int64
+	switch version := header[len(magic)]; {
+	case string(header[:len(magic)]) != magic || version > formatVersion:
+		return false, fmt.Errorf("%w: %s has an unknown format", errCorrupt, path)
+	case version == 1:
+		scale = 1000
+	default:
+		scale = 1
+	}
+
+	for {
+		op, key, e, err := readRecord(r, scale)
+		switch {
+		case err == io.EOF:
+			return true, nil
+		case err == io.ErrUnexpectedEOF && torn:
+			return true, nil
+		case err != nil:
+			return false, fmt.Errorf("%w: %s: %v", errCorrupt, path, err)
+		}
+		apply(op, key, e)
+	}
+}
+
+// appendRecord appends the encoding of a record to buf: the operation,
+// then the key, the value and the expiry of sets, lengths as uvarints
+func appendRecord(buf []byte, op byte, key string, e entry) []byte {
+	buf = append(buf, op)
+	if op == opReset {
+		return buf
+	}
+	buf = appendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+	if op == opSet {
+		buf = appendUvarint(buf, uint64(len(e.data)))
+		buf = append(buf, e.data...)
+		buf = appendUvarint(buf, uint64(e.expiry))
+	}
+	return buf
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+// readRecord decodes a record and multiplies its expiry by scale, io.EOF
+// means there are no more records and io.ErrUnexpectedEOF that the last one
+// is truncated
+func readRecord(r *bufio.Reader, scale int64) (byte, string, entry, error) {
+	op, err := r.ReadByte()
+	if err != nil {
+		return 0, "", entry{}, err
+	}
+	if op == opReset {
+		return op, "", entry{}, nil
+	}
+	if op != opSet && op != opDelete {
+		return 0, "", entry{}, fmt.Errorf("unknown operation %q", op)
+	}
+
+	key, err := readBytes(r)
+	if err != nil || op == opDelete {
+		return op, string(key), entry{}, err
+	}
+	val, err := readBytes(r)
+	if err != nil {
+		return 0, "", entry{}, err
+	}
+	expiry, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, "", entry{}, unexpected(err)
+	}
+	return op, string(key), entry{val, int64(expiry) * scale}, nil
+}
+
+func readBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	if n > maxRecordSize {
+		return nil, fmt.Errorf("record of %d bytes", n)
+	}
+	b := make([]byte, n)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return nil, unexpected(err)
+	}
+	return b, nil
+}
+
+// unexpected turns io.EOF in the middle of a record into
+// io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// appendLog records every change between two snapshots. Records are
+// written with the lock of the shard held, so the log has the same order
+// as the changes of a key. A nil *appendLog discards everything.
+type appendLog struct {
+	mux  sync.Mutex
+	path string
+	f    *os.File
+	buf  []byte
+	// err is the first failed write, logging stops until the next
+	// rotation since the snapshot after it contains the lost changes
+	err    error
+	closed bool
+}
+
+// openLog creates an empty log at path
+func openLog(path string) (*appendLog, error) {
+	l := &appendLog{path: path}
+	if err := l.create(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *appendLog) create() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append([]byte(logMagic), formatVersion)); err != nil {
+		_ = f.Close()
+		return err
+	}
+	l.f = f
+	return nil
+}
+
+func (l *appendLog) set(key string, e entry) { l.write(opSet, key, e) }
+func (l *appendLog) delete(key string)       { l.write(opDelete, key, entry{}) }
+func (l *appendLog) reset()                  { l.write(opReset, "", entry{}) }
+
+func (l *appendLog) write(op byte, key string, e entry) {
+	if l == nil {
+		return
+	}
+	l.mux.Lock()
+	if l.err == nil && l.f != nil {
+		l.buf = appendRecord(l.buf[:0], op, key, e)
+		_, l.err = l.f.Write(l.buf)
+	}
+	l.mux.Unlock()
+}
+
+// rotate moves the log aside and starts a new one. If an older log is
+// still around because the last snapshot failed, the current log keeps
+// growing instead, replaying both in order stays correct.
+func (l *appendLog) rotate() error {
+	if l == nil {
+		return nil
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	if _, err := os.Stat(l.path + ".old"); err == nil {
+		return nil
+	}
+	if l.f != nil {
+		_ = l.f.Close()
+		l.f = nil
+	}
+	if err := os.Rename(l.path, l.path+".old"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	l.err = l.create()
+	return l.err
+}
+
+// dropOld removes the log rotated before the last successful snapshot
+func (l *appendLog) dropOld() error {
+	if l == nil {
+		return nil
+	}
+	if err := os.Remove(l.path + ".old"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// close closes the log file and returns the first failed write
+func (l *appendLog) close() error {
+	if l == nil {
+		return nil
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.closed = true
+	err := l.err
+	if l.f != nil {
+		if cerr := l.f.Close(); err == nil {
+			err = cerr
+		}
+		l.f = nil
+	}
+	return err
+}
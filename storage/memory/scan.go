@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// defaultPageSize is used by Scan when pageSize is not positive
+const defaultPageSize = 100
+
+// Scan calls fn with the unexpired keys starting with prefix in sorted
+// order, at most pageSize keys at a time. The keys are collected before
+// fn is called, one shard at a time, so fn may modify the storage.
+func (s *Storage) Scan(ctx context.Context, prefix string, pageSize int, fn func(keys []string) error) error {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	var keys []string
+	c := newClock()
+	for _, sh := range s.shards {
+		sh.mux.RLock()
+		for key, v := range sh.db {
+			if strings.HasPrefix(key, prefix) && c.live(v.expiry) {
+				keys = append(keys, key)
+			}
+		}
+		sh.mux.RUnlock()
+	}
+	sort.Strings(keys)
+
+	for len(keys) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := pageSize
+		if n > len(keys) {
+			n = len(keys)
+		}
+		if err := fn(keys[:n:n]); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+// DeletePrefix deletes every key starting with prefix, one shard at a time
+func (s *Storage) DeletePrefix(ctx context.Context, prefix string) error {
+	for _, sh := range s.shards {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sh.mux.Lock()
+		for key := range sh.db {
+			if strings.HasPrefix(key, prefix) {
+				sh.remove(key)
+			}
+		}
+		sh.mux.Unlock()
+	}
+	return nil
+}
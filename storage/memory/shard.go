@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"sync"
+)
+
+// gcBatchSize is the maximum number of expired keys the gc deletes while
+// holding the lock of a shard
+const gcBatchSize = 1024
+
+// shard is a part of the storage with its own lock, keys are spread over
+// the shards by hash
+type shard struct {
+	mux   sync.RWMutex
+	db    map[string]entry
+	bytes int64
+
+	maxEntries int
+	maxBytes   int64
+	policy     Policy
+
+	// log is nil unless Config.AppendLog is set
+	log *appendLog
+}
+
+// shard returns the shard of key
+func (s *Storage) shard(key string) *shard {
+	if len(s.shards) == 1 {
+		return s.shards[0]
+	}
+	// FNV-1a, inlined to not allocate
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return s.shards[h&s.mask]
+}
+
+// share returns the part of limit of shard i out of n, the first shards
+// take the remainder so the parts add up to limit
+func share(limit int64, n, i int) int64 {
+	part := limit / int64(n)
+	if int64(i) < limit%int64(n) {
+		part++
+	}
+	return part
+}
+
+// group returns keys grouped by shard
+func (s *Storage) group(keys []string) map[*shard][]string {
+	groups := make(map[*shard][]string, len(s.shards))
+	for _, key := range keys {
+		sh := s.shard(key)
+		groups[sh] = append(groups[sh], key)
+	}
+	return groups
+}
+
+// live returns the entry of key if it exists and has not expired, the
+// caller must hold the lock
+func (sh *shard) live(key string, c *clock) (entry, bool) {
+	v, ok := sh.db[key]
+	if !ok || !c.live(v.expiry) {
+		return entry{}, false
+	}
+	return v, true
+}
+
+// clear deletes all keys, the caller must hold the write lock
+func (sh *shard) clear() {
+	sh.db = make(map[string]entry)
+	sh.bytes = 0
+	if sh.policy != nil {
+		sh.policy.Reset()
+	}
+}
+
+// gc deletes the keys that expired at now in unix milliseconds, at most
+// gcBatchSize keys per lock, and returns expired for reuse
+func (sh *shard) gc(now int64, expired []string) []string {
+	expired = expired[:0]
+	sh.mux.RLock()
+	for id, v := range sh.db {
+		if v.expiry != 0 && v.expiry <= now {
+			expired = append(expired, id)
+		}
+	}
+	sh.mux.RUnlock()
+
+	for batch := expired; len(batch) > 0; {
+		n := gcBatchSize
+		if n > len(batch) {
+			n = len(batch)
+		}
+		sh.mux.Lock()
+		// Double-checked locking.
+		// We might have replaced the item in the meantime.
+		for _, key := range batch[:n] {
+			if v, ok := sh.db[key]; ok && v.expiry != 0 && v.expiry <= now {
+				sh.remove(key)
+			}
+		}
+		sh.mux.Unlock()
+		batch = batch[n:]
+	}
+	return expired
+}
@@ -0,0 +1,113 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SetIfNotExists sets key with value if key does not exist. It upserts
+// over expired documents and relies on the unique index on `key` to reject
+// live ones, and reports whether the value was set
+func (s *Storage) SetIfNotExists(key string, val []byte, exp time.Duration) (bool, error) {
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(val) <= 0 {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	filter := bson.M{"key": key, "exp": bson.M{"$lte": now}}
+	_, err := s.col.UpdateOne(context.Background(), filter, setValue(val, now, exp), options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CompareAndSwap sets key to new if its value is old with
+// findOneAndUpdate, a nil old means key must not exist. It reports whether
+// the value was swapped
+func (s *Storage) CompareAndSwap(key string, old, new []byte, exp time.Duration) (bool, error) {
+	if old == nil {
+		return s.SetIfNotExists(key, new, exp)
+	}
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(new) <= 0 {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	return s.swap(key, old, now, setValue(new, now, exp))
+}
+
+// Increment adds delta to the counter at key and returns the new value, a
+// missing key starts at 0 and expires after exp. Values are stored as
+// bytes, so it retries a compare and swap until no other write interferes
+func (s *Storage) Increment(key string, delta int64, exp time.Duration) (int64, error) {
+	if len(key) <= 0 {
+		return 0, nil
+	}
+
+	for {
+		data, err := s.Get(key)
+		if err != nil {
+			return 0, err
+		}
+
+		if data == nil {
+			ok, err := s.SetIfNotExists(key, strconv.AppendInt(nil, delta, 10), exp)
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				return delta, nil
+			}
+			continue
+		}
+
+		n, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("mongodb: value of %q is not an integer", key)
+		}
+		n += delta
+
+		// existing counters keep their expiration
+		update := bson.M{"$set": bson.M{"value": strconv.AppendInt(nil, n, 10)}}
+		ok, err := s.swap(key, data, time.Now().UTC(), update)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return n, nil
+		}
+	}
+}
+
+// swap applies update to the live document of key if its value is old
+func (s *Storage) swap(key string, old []byte, now time.Time, update bson.M) (bool, error) {
+	filter := bson.M{
+		"key":   key,
+		"value": old,
+		"$or": bson.A{
+			bson.M{"exp": bson.M{"$exists": false}},
+			bson.M{"exp": bson.M{"$gt": now}},
+		},
+	}
+	err := s.col.FindOneAndUpdate(context.Background(), filter, update).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// setValue returns the update that sets the value and expiration of a
+// document
+func setValue(val []byte, now time.Time, exp time.Duration) bson.M {
+	if exp == 0 {
+		return bson.M{"$set": bson.M{"value": val}, "$unset": bson.M{"exp": ""}}
+	}
+	return bson.M{"$set": bson.M{"value": val, "exp": now.Add(exp)}}
+}
@@ -0,0 +1,73 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/20326/flexbox/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetMany gets the values of keys with a single `$in` query, missing and
+// expired keys are left out
+func (s *Storage) GetMany(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	ctx := context.Background()
+	cur, err := s.col.Find(ctx, bson.M{"key": bson.M{"$in": keys}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	now := time.Now().Unix()
+	for cur.Next(ctx) {
+		var doc item
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		if !doc.Expiration.IsZero() && doc.Expiration.Unix() <= now {
+			continue
+		}
+		values[doc.Key] = doc.Value
+	}
+	return values, cur.Err()
+}
+
+// SetMany upserts all entries with one unordered bulk write
+func (s *Storage) SetMany(entries map[string]storage.Entry) error {
+	models := make([]mongo.WriteModel, 0, len(entries))
+	for key, e := range entries {
+		// Ain't Nobody Got Time For That
+		if len(key) <= 0 || len(e.Value) <= 0 {
+			continue
+		}
+		doc := item{Key: key, Value: e.Value}
+		if e.Exp != 0 {
+			doc.Expiration = time.Now().Add(e.Exp).UTC()
+		}
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"key": key}).
+			SetReplacement(doc).
+			SetUpsert(true))
+	}
+	if len(models) == 0 {
+		return nil
+	}
+	_, err := s.col.BulkWrite(context.Background(), models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// DeleteMany deletes all keys with a single `$in` delete
+func (s *Storage) DeleteMany(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := s.col.DeleteMany(context.Background(), bson.M{"key": bson.M{"$in": keys}})
+	return err
+}
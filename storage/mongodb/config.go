@@ -1,58 +1,80 @@
 package mongodb
 
+import (
+	"time"
+
+	"github.com/20326/flexbox/storage"
+)
+
 // Config defines the config for storage.
 type Config struct {
 	// Connection string to use for DB. Will override all other authentication values if used
 	//
 	// Optional. Default is ""
-	ConnectionURI string
+	ConnectionURI string `yaml:"connectionURI"`
 
 	// Host name where the DB is hosted
 	//
 	// Optional. Default is "127.0.0.1"
-	Host string
+	Host string `yaml:"host" default:"127.0.0.1"`
 
 	// Port where the DB is listening on
 	//
 	// Optional. Default is 27017
-	Port int
+	Port int `yaml:"port" default:"27017"`
 
 	// Server username
 	//
 	// Optional. Default is ""
-	Username string
+	Username string `yaml:"username"`
 
 	// Server password
 	//
 	// Optional. Default is ""
-	Password string
+	Password string `yaml:"password"`
 
 	// Database name
 	//
 	// Optional. Default is "fiber"
-	Database string
+	Database string `yaml:"database" default:"fiber"`
 
-	// Collection name
+	// Collection name. New creates a unique index on the keys and fails
+	// if the collection already has documents with the same key
 	//
 	// Optional. Default is "fiber_storage"
-	Collection string
+	Collection string `yaml:"collection" default:"fiber_storage"`
 
 	// Reset clears any existing keys in existing Table
 	//
 	// Optional. Default is false
-	Reset bool
-}
+	Reset bool `yaml:"reset"`
+
+	// ConnectTimeout limits every connection attempt of NewWithContext
+	//
+	// Optional. Default is 20 * time.Second
+	ConnectTimeout time.Duration `yaml:"connectTimeout" default:"20s"`
+
+	// ConnectRetries is the number of times the initial ping is retried
+	// by NewWithContext before giving up
+	//
+	// Optional. Default is 0
+	ConnectRetries int `yaml:"connectRetries"`
 
-// ConfigDefault is the default config
-var ConfigDefault = Config{
-	ConnectionURI: "",
-	Host:          "127.0.0.1",
-	Port:          27017,
-	Database:      "fiber",
-	Collection:    "fiber_storage",
-	Reset:         false,
+	// ConnectBackoff is the wait before the first retry, it doubles after
+	// every attempt
+	//
+	// Optional. Default is 100 * time.Millisecond
+	ConnectBackoff time.Duration `yaml:"connectBackoff" default:"100ms"`
 }
 
+// ConfigDefault is the default config, built from the default tags of
+// Config
+var ConfigDefault = func() Config {
+	var cfg Config
+	storage.Defaults(&cfg)
+	return cfg
+}()
+
 // Helper function to set default values
 func configDefault(config ...Config) Config {
 	// Return default config if nothing provided
@@ -76,5 +98,11 @@ func configDefault(config ...Config) Config {
 	if cfg.Collection == "" {
 		cfg.Collection = ConfigDefault.Collection
 	}
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = ConfigDefault.ConnectTimeout
+	}
+	if cfg.ConnectBackoff <= 0 {
+		cfg.ConnectBackoff = ConfigDefault.ConnectBackoff
+	}
 	return cfg
 }
@@ -0,0 +1,14 @@
+package mongodb
+
+import (
+	"testing"
+
+	"github.com/20326/flexbox/storage"
+	"github.com/20326/flexbox/storage/storagetest"
+)
+
+func Test_MongoDB_Conformance(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Storage {
+		return New()
+	})
+}
@@ -0,0 +1,50 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TTL returns the time left before key expires, ok is false if key does
+// not exist
+func (s *Storage) TTL(key string) (time.Duration, bool, error) {
+	var doc item
+	opts := options.FindOne().SetProjection(bson.M{"exp": 1})
+	if err := s.col.FindOne(context.Background(), bson.M{"key": key}, opts).Decode(&doc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	if doc.Expiration.IsZero() {
+		return 0, true, nil
+	}
+	ttl := time.Until(doc.Expiration)
+	if ttl <= 0 {
+		return 0, false, nil
+	}
+	return ttl, true, nil
+}
+
+// Touch sets the expiration of key to exp from now, 0 means no expiration
+func (s *Storage) Touch(key string, exp time.Duration) error {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"key": key,
+		"$or": bson.A{
+			bson.M{"exp": bson.M{"$exists": false}},
+			bson.M{"exp": bson.M{"$gt": now}},
+		},
+	}
+	update := bson.M{"$unset": bson.M{"exp": ""}}
+	if exp != 0 {
+		update = bson.M{"$set": bson.M{"exp": now.Add(exp)}}
+	}
+	_, err := s.col.UpdateOne(context.Background(), filter, update)
+	return err
+}
@@ -3,6 +3,7 @@ module storage/mongodb
 go 1.18
 
 require (
+	github.com/20326/flexbox v0.0.0
 	github.com/gofiber/utils v1.0.1
 	go.mongodb.org/mongo-driver v1.11.4
 )
@@ -20,3 +21,5 @@ require (
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
 	golang.org/x/text v0.3.8 // indirect
 )
+
+replace github.com/20326/flexbox => ../..
@@ -18,6 +18,9 @@ type Storage struct {
 	db    *mongo.Database
 	col   *mongo.Collection
 	items *sync.Pool
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 type item struct {
@@ -27,8 +30,19 @@ type item struct {
 	Expiration time.Time          `json:"exp,omitempty" bson:"exp,omitempty"`
 }
 
-// New creates a new MongoDB storage
+// New creates a new MongoDB storage, it panics if MongoDB cannot be reached
 func New(config ...Config) *Storage {
+	store, err := NewWithContext(context.Background(), config...)
+	if err != nil {
+		panic(err)
+	}
+	return store
+}
+
+// NewWithContext creates a new MongoDB storage and returns an error instead
+// of panicking. ctx bounds connecting and creating the TTL index, including
+// retries, every connection attempt is limited by Config.ConnectTimeout.
+func NewWithContext(ctx context.Context, config ...Config) (*Storage, error) {
 	// Set default config
 	cfg := configDefault(config...)
 
@@ -59,18 +73,24 @@ func New(config ...Config) *Storage {
 	// Create mongo client
 	client, err := mongo.NewClient(opt)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.TODO(), 20*time.Second)
-	defer cancel()
-	if err = client.Connect(ctx); err != nil {
-		panic(err)
+	connectCtx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	err = client.Connect(connectCtx)
+	cancel()
+	if err != nil {
+		return nil, err
 	}
 
 	// verify that the client can connect
-	if err = client.Ping(context.Background(), nil); err != nil {
-		panic(err)
+	if err = retry(ctx, cfg, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+		defer cancel()
+		return client.Ping(ctx, nil)
+	}); err != nil {
+		_ = client.Disconnect(context.Background())
+		return nil, err
 	}
 
 	// Get collection from database
@@ -78,8 +98,9 @@ func New(config ...Config) *Storage {
 	col := db.Collection(cfg.Collection)
 
 	if cfg.Reset {
-		if err = col.Drop(context.Background()); err != nil {
-			panic(err)
+		if err = col.Drop(ctx); err != nil {
+			_ = client.Disconnect(context.Background())
+			return nil, err
 		}
 	}
 
@@ -96,8 +117,18 @@ func New(config ...Config) *Storage {
 		Options: options.Index().SetExpireAfterSeconds(0),
 	}
 
-	if _, err := col.Indexes().CreateOne(ctx, indexModel); err != nil {
-		panic(err)
+	// keys are unique, which SetIfNotExists relies on
+	keyIndexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	if _, err := col.Indexes().CreateMany(ctx, []mongo.IndexModel{indexModel, keyIndexModel}); err != nil {
+		_ = client.Disconnect(context.Background())
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("mongodb: collection %s has documents with the same key, remove them or set Config.Reset: %w", cfg.Collection, err)
+		}
+		return nil, err
 	}
 
 	store := &Storage{
@@ -109,15 +140,41 @@ func New(config ...Config) *Storage {
 			},
 		},
 	}
-	return store
+	return store, nil
+}
+
+// retry calls fn until it succeeds, cfg.ConnectRetries is exhausted or ctx
+// is done, doubling cfg.ConnectBackoff after every attempt.
+func retry(ctx context.Context, cfg Config, fn func(context.Context) error) error {
+	backoff := cfg.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= cfg.ConnectRetries {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
 }
 
 // Get value by key
 func (s *Storage) Get(key string) ([]byte, error) {
+	return s.GetWithContext(context.Background(), key)
+}
+
+// GetWithContext gets value by key, ctx bounds the request
+func (s *Storage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
 	if len(key) <= 0 {
 		return nil, nil
 	}
-	res := s.col.FindOne(context.Background(), bson.M{"key": key})
+	res := s.col.FindOne(ctx, bson.M{"key": key})
 	item := s.acquireItem()
 
 	if err := res.Err(); err != nil {
@@ -143,8 +200,12 @@ func (s *Storage) Get(key string) ([]byte, error) {
 // Set key with value, replace if document exits
 //
 // document will be remove automatically if exp is set, based on MongoDB TTL Indexes
-// Set key with value
 func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
+	return s.SetWithContext(context.Background(), key, val, exp)
+}
+
+// SetWithContext sets key with value, ctx bounds the request
+func (s *Storage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
 	// Ain't Nobody Got Time For That
 	if len(key) <= 0 || len(val) <= 0 {
 		return nil
@@ -158,7 +219,7 @@ func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
 	if exp != 0 {
 		item.Expiration = time.Now().Add(exp).UTC()
 	}
-	_, err := s.col.ReplaceOne(context.Background(), filter, item, options.Replace().SetUpsert(true))
+	_, err := s.col.ReplaceOne(ctx, filter, item, options.Replace().SetUpsert(true))
 
 	s.releaseItem(item)
 	return err
@@ -166,22 +227,38 @@ func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
 
 // Delete document by key
 func (s *Storage) Delete(key string) error {
+	return s.DeleteWithContext(context.Background(), key)
+}
+
+// DeleteWithContext deletes key by key, ctx bounds the request
+func (s *Storage) DeleteWithContext(ctx context.Context, key string) error {
 	// Ain't Nobody Got Time For That
 	if len(key) <= 0 {
 		return nil
 	}
-	_, err := s.col.DeleteOne(context.Background(), bson.M{"key": key})
+	_, err := s.col.DeleteOne(ctx, bson.M{"key": key})
 	return err
 }
 
-// Reset all keys by drop collection
+// Reset all keys
 func (s *Storage) Reset() error {
-	return s.col.Drop(context.Background())
+	return s.ResetWithContext(context.Background())
+}
+
+// ResetWithContext resets all keys, ctx bounds the request. Documents are
+// deleted rather than the collection dropped, which would drop the unique
+// key index SetIfNotExists relies on.
+func (s *Storage) ResetWithContext(ctx context.Context) error {
+	_, err := s.col.DeleteMany(ctx, bson.M{})
+	return err
 }
 
-// Close the database
+// Close the database, calling it more than once returns the first result
 func (s *Storage) Close() error {
-	return s.db.Client().Disconnect(context.Background())
+	s.closeOnce.Do(func() {
+		s.closeErr = s.db.Client().Disconnect(context.Background())
+	})
+	return s.closeErr
 }
 
 // Acquire item from pool
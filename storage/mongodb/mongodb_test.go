@@ -1,121 +1,44 @@
 package mongodb
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/gofiber/utils"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 var testStore = New(Config{
 	Reset: true,
 })
 
-func Test_MongoDB_Set(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
+func Test_MongoDB_Reset_UniqueIndex(t *testing.T) {
+	utils.AssertEqual(t, nil, testStore.Set("john", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, testStore.Reset())
 
-	err := testStore.Set(key, val, 0)
+	// Reset deletes the documents and keeps the unique key index
+	cursor, err := testStore.col.Indexes().List(context.Background())
 	utils.AssertEqual(t, nil, err)
-}
-
-func Test_MongoDB_Set_Override(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
+	var indexes []bson.M
+	utils.AssertEqual(t, nil, cursor.All(context.Background(), &indexes))
+	unique := false
+	for _, index := range indexes {
+		if key, ok := index["key"].(bson.M); ok && len(key) == 1 && key["key"] != nil {
+			unique, _ = index["unique"].(bool)
+		}
+	}
+	utils.AssertEqual(t, true, unique)
 
-	err := testStore.Set(key, val, 0)
+	// which makes the second SetIfNotExists fail on the duplicate key
+	ok, err := testStore.SetIfNotExists("lock", []byte("owner-a"), time.Minute)
 	utils.AssertEqual(t, nil, err)
-
-	err = testStore.Set(key, val, 0)
+	utils.AssertEqual(t, true, ok)
+	ok, err = testStore.SetIfNotExists("lock", []byte("owner-b"), time.Minute)
 	utils.AssertEqual(t, nil, err)
-}
-
-func Test_MongoDB_Get(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
-
-	err := testStore.Set(key, val, 0)
-	utils.AssertEqual(t, nil, err)
-
-	result, err := testStore.Get(key)
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, val, result)
-}
-
-func Test_MongoDB_Set_Expiration(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-		exp = 1 * time.Second
-	)
+	utils.AssertEqual(t, false, ok)
 
-	err := testStore.Set(key, val, exp)
-	utils.AssertEqual(t, nil, err)
-
-	time.Sleep(1100 * time.Millisecond)
-}
-
-func Test_MongoDB_Get_Expired(t *testing.T) {
-	var (
-		key = "john"
-	)
-
-	result, err := testStore.Get(key)
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-}
-
-func Test_MongoDB_Get_NotExist(t *testing.T) {
-
-	result, err := testStore.Get("notexist")
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-}
-
-func Test_MongoDB_Delete(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
-
-	err := testStore.Set(key, val, 0)
-	utils.AssertEqual(t, nil, err)
-
-	err = testStore.Delete(key)
-	utils.AssertEqual(t, nil, err)
-
-	result, err := testStore.Get(key)
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-}
-
-func Test_MongoDB_Reset(t *testing.T) {
-	var (
-		val = []byte("doe")
-	)
-
-	err := testStore.Set("john1", val, 0)
-	utils.AssertEqual(t, nil, err)
-
-	err = testStore.Set("john2", val, 0)
-	utils.AssertEqual(t, nil, err)
-
-	err = testStore.Reset()
-	utils.AssertEqual(t, nil, err)
-
-	result, err := testStore.Get("john1")
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-
-	result, err = testStore.Get("john2")
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
+	utils.AssertEqual(t, nil, testStore.Delete("lock"))
 }
 
 func Test_MongoDB_Close(t *testing.T) {
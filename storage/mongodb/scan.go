@@ -0,0 +1,71 @@
+package mongodb
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultPageSize is used by Scan when pageSize is not positive
+const defaultPageSize = 100
+
+// Scan calls fn with the unexpired keys starting with prefix in sorted
+// order, at most pageSize keys at a time. Pages are read with an anchored
+// regex on `key` after the last key of the previous page, so fn may modify
+// the storage.
+func (s *Storage) Scan(ctx context.Context, prefix string, pageSize int, fn func(keys []string) error) error {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	pattern := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(prefix)}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "key", Value: 1}}).
+		SetProjection(bson.M{"key": 1}).
+		SetLimit(int64(pageSize))
+
+	var last string
+	for {
+		filter := bson.M{
+			"key": bson.M{"$regex": pattern, "$gt": last},
+			"$or": bson.A{
+				bson.M{"exp": bson.M{"$exists": false}},
+				bson.M{"exp": bson.M{"$gt": time.Now().UTC()}},
+			},
+		}
+		cur, err := s.col.Find(ctx, filter, opts)
+		if err != nil {
+			return err
+		}
+		var docs []item
+		if err := cur.All(ctx, &docs); err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+
+		keys := make([]string, len(docs))
+		for i := range docs {
+			keys[i] = docs[i].Key
+		}
+		if err := fn(keys); err != nil {
+			return err
+		}
+		if len(keys) < pageSize {
+			return nil
+		}
+		last = keys[len(keys)-1]
+	}
+}
+
+// DeletePrefix deletes every key starting with prefix with a single
+// DeleteMany on an anchored regex
+func (s *Storage) DeletePrefix(ctx context.Context, prefix string) error {
+	pattern := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(prefix)}
+	_, err := s.col.DeleteMany(ctx, bson.M{"key": bson.M{"$regex": pattern}})
+	return err
+}
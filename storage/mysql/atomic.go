@@ -0,0 +1,112 @@
+package mysql
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SetIfNotExists sets key with value if key does not exist, using
+// `INSERT ... ON DUPLICATE KEY UPDATE`. It reports whether the value was set
+func (s *Storage) SetIfNotExists(key string, val []byte, exp time.Duration) (bool, error) {
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(val) <= 0 {
+		return false, nil
+	}
+	now := time.Now()
+	e := expiration(now, exp)
+	res, err := s.db.Exec(s.sqlInsertNX, key, val, e, now.Unix(), val, now.Unix(), e)
+	if err != nil {
+		return false, err
+	}
+	// 1 for an insert, 2 for an update of an expired row
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CompareAndSwap sets key to new if its value is old, a nil old means key
+// must not exist. The row is locked with `SELECT ... FOR UPDATE` while
+// the value is compared, so a swap of a value with itself is reported
+// too. It reports whether the value was swapped
+func (s *Storage) CompareAndSwap(key string, old, new []byte, exp time.Duration) (bool, error) {
+	if old == nil {
+		return s.SetIfNotExists(key, new, exp)
+	}
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(new) <= 0 {
+		return false, nil
+	}
+	var swapped bool
+	err := s.inTx(func(tx *sql.Tx) error {
+		var (
+			data []byte
+			e    int64
+		)
+		now := time.Now()
+		err := tx.QueryRow(s.sqlSelectLock, key).Scan(&data, &e)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if (e != 0 && e <= now.Unix()) || !bytes.Equal(data, old) {
+			return nil
+		}
+		if _, err = tx.Exec(s.sqlCAS, new, expiration(now, exp), key); err != nil {
+			return err
+		}
+		swapped = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+// Increment adds delta to the counter at key and returns the new value, a
+// missing key starts at 0 and expires after exp
+func (s *Storage) Increment(key string, delta int64, exp time.Duration) (int64, error) {
+	if len(key) <= 0 {
+		return 0, nil
+	}
+
+	var n int64
+	err := s.inTx(func(tx *sql.Tx) error {
+		// Create the counter if it does not exist, the write locks the
+		// row until the transaction ends
+		now, zero := time.Now(), []byte("0")
+		e := expiration(now, exp)
+		if _, err := tx.Exec(s.sqlInsertNX, key, zero, e, now.Unix(), zero, now.Unix(), e); err != nil {
+			return err
+		}
+
+		var data []byte
+		if err := tx.QueryRow(s.sqlSelectLock, key).Scan(&data, new(int64)); err != nil {
+			return err
+		}
+		value, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return fmt.Errorf("mysql: value of %q is not an integer", key)
+		}
+
+		n = value + delta
+		_, err = tx.Exec(s.sqlUpdate, strconv.AppendInt(nil, n, 10), key)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// expiration returns the expiration column of exp, 0 means no expiration
+func expiration(now time.Time, exp time.Duration) int64 {
+	if exp == 0 {
+		return 0
+	}
+	return now.Add(exp).Unix()
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+
+	"github.com/20326/flexbox/storage"
 )
 
 // Config defines the config for storage.
@@ -11,52 +13,66 @@ type Config struct {
 	// DB Will override ConnectionURI and all other authentication values if used
 	//
 	// Optional. Default is nil
-	Db *sql.DB
+	Db *sql.DB `yaml:"-"`
 
 	// Connection string to use for DB. Will override all other authentication values if used
 	//
 	// Optional. Default is ""
-	ConnectionURI string
+	ConnectionURI string `yaml:"connectionURI"`
 
 	// Host name where the DB is hosted
 	//
 	// Optional. Default is "127.0.0.1"
-	Host string
+	Host string `yaml:"host" default:"127.0.0.1"`
 
 	// Port where the DB is listening on
 	//
 	// Optional. Default is 3306
-	Port int
+	Port int `yaml:"port" default:"3306"`
 
 	// Server username
 	//
 	// Optional. Default is ""
-	Username string
+	Username string `yaml:"username"`
 
 	// Server password
 	//
 	// Optional. Default is ""
-	Password string
+	Password string `yaml:"password"`
 
 	// Database name
 	//
 	// Optional. Default is "fiber"
-	Database string
+	Database string `yaml:"database" default:"fiber"`
 
-	// Table name
+	// Table name. Keys are case-sensitive in tables created by New, older
+	// tables compare keys with their own collation, case-insensitive by
+	// default, while Scan and DeletePrefix always match prefixes by case
 	//
 	// Optional. Default is "fiber_storage"
-	Table string
+	Table string `yaml:"table" default:"fiber_storage"`
 
 	// Reset clears any existing keys in existing Table
 	//
 	// Optional. Default is false
-	Reset bool
+	Reset bool `yaml:"reset"`
 
 	// Time before deleting expired keys
 	//
 	// Optional. Default is 10 * time.Second
-	GCInterval time.Duration
+	GCInterval time.Duration `yaml:"gcInterval" default:"10s"`
+
+	// ConnectRetries is the number of times the initial ping is retried
+	// by NewWithContext before giving up
+	//
+	// Optional. Default is 0
+	ConnectRetries int `yaml:"connectRetries"`
+
+	// ConnectBackoff is the wait before the first retry, it doubles after
+	// every attempt
+	//
+	// Optional. Default is 100 * time.Millisecond
+	ConnectBackoff time.Duration `yaml:"connectBackoff" default:"100ms"`
 
 	////////////////////////////////////
 	// Adaptor related config options //
@@ -67,20 +83,17 @@ type Config struct {
 	connMaxLifetime time.Duration
 }
 
-// ConfigDefault is the default config
-var ConfigDefault = Config{
-	Db:              nil,
-	ConnectionURI:   "",
-	Host:            "127.0.0.1",
-	Port:            3306,
-	Database:        "fiber",
-	Table:           "fiber_storage",
-	Reset:           false,
-	GCInterval:      10 * time.Second,
-	maxOpenConns:    100,
-	maxIdleConns:    100,
-	connMaxLifetime: 1 * time.Second,
-}
+// ConfigDefault is the default config, built from the default tags of
+// Config
+var ConfigDefault = func() Config {
+	cfg := Config{
+		maxOpenConns:    100,
+		maxIdleConns:    100,
+		connMaxLifetime: 1 * time.Second,
+	}
+	storage.Defaults(&cfg)
+	return cfg
+}()
 
 func (c Config) dsn() string {
 	if c.ConnectionURI != "" {
@@ -115,5 +128,8 @@ func configDefault(config ...Config) Config {
 	if int(cfg.GCInterval.Seconds()) <= 0 {
 		cfg.GCInterval = ConfigDefault.GCInterval
 	}
+	if cfg.ConnectBackoff <= 0 {
+		cfg.ConnectBackoff = ConfigDefault.ConnectBackoff
+	}
 	return cfg
 }
@@ -0,0 +1,19 @@
+package mysql
+
+import (
+	"os"
+	"testing"
+
+	"github.com/20326/flexbox/storage"
+	"github.com/20326/flexbox/storage/storagetest"
+)
+
+func Test_MYSQL_Conformance(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Storage {
+		return New(Config{
+			Database: os.Getenv("MYSQL_DATABASE"),
+			Username: os.Getenv("MYSQL_USERNAME"),
+			Password: os.Getenv("MYSQL_PASSWORD"),
+		})
+	})
+}
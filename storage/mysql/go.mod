@@ -3,6 +3,10 @@ module storage/mysql
 go 1.17
 
 require (
+	github.com/20326/flexbox v0.0.0
 	github.com/go-sql-driver/mysql v1.7.0
 	github.com/gofiber/utils v1.0.1
+	go.uber.org/goleak v1.1.12
 )
+
+replace github.com/20326/flexbox => ../..
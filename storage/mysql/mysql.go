@@ -1,9 +1,11 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -14,12 +16,27 @@ type Storage struct {
 	db         *sql.DB
 	gcInterval time.Duration
 	done       chan struct{}
+	gcDone     chan struct{}
+	cancelGC   context.CancelFunc
+	closeOnce  sync.Once
+	closeErr   error
 
 	sqlSelect string
 	sqlInsert string
 	sqlDelete string
 	sqlReset  string
 	sqlGC     string
+
+	sqlSelectMany   string
+	sqlDeleteMany   string
+	sqlScan         string
+	sqlDeletePrefix string
+	sqlTTL          string
+	sqlTouch        string
+	sqlInsertNX     string
+	sqlCAS          string
+	sqlSelectLock   string
+	sqlUpdate       string
 }
 
 var (
@@ -28,7 +45,7 @@ var (
 	dropQuery = "DROP TABLE IF EXISTS %s;"
 	initQuery = []string{
 		`CREATE TABLE IF NOT EXISTS %s ( 
-			k  VARCHAR(64) NOT NULL DEFAULT '', 
+			k  VARCHAR(64) CHARACTER SET utf8 COLLATE utf8_bin NOT NULL DEFAULT '', 
 			v  BLOB NOT NULL, 
 			e  BIGINT NOT NULL DEFAULT '0', 
 			PRIMARY KEY (k)
@@ -38,8 +55,18 @@ var (
 		WHERE table_name = '%s' AND COLUMN_NAME = 'v';`
 )
 
-// New creates a new storage
+// New creates a new storage, it panics if the database cannot be reached
 func New(config ...Config) *Storage {
+	store, err := NewWithContext(context.Background(), config...)
+	if err != nil {
+		panic(err)
+	}
+	return store
+}
+
+// NewWithContext creates a new storage and returns an error instead of
+// panicking. ctx bounds connecting to the database, including retries.
+func NewWithContext(ctx context.Context, config ...Config) (*Storage, error) {
 	var err error
 	var db *sql.DB
 
@@ -53,7 +80,7 @@ func New(config ...Config) *Storage {
 		// Create db
 		db, err = sql.Open("mysql", cfg.dsn())
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 
 		// Set options
@@ -62,57 +89,104 @@ func New(config ...Config) *Storage {
 		db.SetConnMaxLifetime(cfg.connMaxLifetime)
 	}
 
+	// fail closes the database unless it is the caller's
+	fail := func(err error) (*Storage, error) {
+		if cfg.Db == nil {
+			_ = db.Close()
+		}
+		return nil, err
+	}
+
 	// Ping database to ensure a connection has been made
-	if err := db.Ping(); err != nil {
-		panic(err)
+	if err := retry(ctx, cfg, db.PingContext); err != nil {
+		return fail(err)
 	}
 
 	// Drop table if Clear set to true
 	if cfg.Reset {
 		query := fmt.Sprintf(dropQuery, cfg.Table)
-		if _, err = db.Exec(query); err != nil {
-			_ = db.Close()
-			panic(err)
+		if _, err = db.ExecContext(ctx, query); err != nil {
+			return fail(err)
 		}
 	}
 
 	// Init database queries
 	for _, query := range initQuery {
 		query = fmt.Sprintf(query, cfg.Table)
-		if _, err := db.Exec(query); err != nil {
-			_ = db.Close()
-			panic(err)
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return fail(err)
 		}
 	}
 
 	// Create storage
 	store := &Storage{
-		gcInterval: cfg.GCInterval,
-		db:         db,
-		done:       make(chan struct{}),
-		sqlSelect:  fmt.Sprintf("SELECT v, e FROM %s WHERE k=?;", cfg.Table),
-		sqlInsert:  fmt.Sprintf("INSERT INTO %s (k, v, e) VALUES (?,?,?) ON DUPLICATE KEY UPDATE v = ?, e = ?", cfg.Table),
-		sqlDelete:  fmt.Sprintf("DELETE FROM %s WHERE k=?", cfg.Table),
-		sqlReset:   fmt.Sprintf("TRUNCATE TABLE %s;", cfg.Table),
-		sqlGC:      fmt.Sprintf("DELETE FROM %s WHERE e <= ? AND e != 0", cfg.Table),
+		gcInterval:      cfg.GCInterval,
+		db:              db,
+		done:            make(chan struct{}),
+		gcDone:          make(chan struct{}),
+		sqlSelect:       fmt.Sprintf("SELECT v, e FROM %s WHERE k=?;", cfg.Table),
+		sqlInsert:       fmt.Sprintf("INSERT INTO %s (k, v, e) VALUES (?,?,?) ON DUPLICATE KEY UPDATE v = ?, e = ?", cfg.Table),
+		sqlDelete:       fmt.Sprintf("DELETE FROM %s WHERE k=?", cfg.Table),
+		sqlReset:        fmt.Sprintf("TRUNCATE TABLE %s;", cfg.Table),
+		sqlGC:           fmt.Sprintf("DELETE FROM %s WHERE e <= ? AND e != 0", cfg.Table),
+		sqlSelectMany:   fmt.Sprintf("SELECT k, v, e FROM %s WHERE k IN (%%s)", cfg.Table),
+		sqlDeleteMany:   fmt.Sprintf("DELETE FROM %s WHERE k IN (%%s)", cfg.Table),
+		sqlScan:         fmt.Sprintf("SELECT k FROM %s WHERE k LIKE BINARY ? ESCAPE '!' AND k > ? AND (e = 0 OR e > ?) ORDER BY k LIMIT ?", cfg.Table),
+		sqlTTL:          fmt.Sprintf("SELECT e FROM %s WHERE k=?", cfg.Table),
+		sqlDeletePrefix: fmt.Sprintf("DELETE FROM %s WHERE k LIKE BINARY ? ESCAPE '!'", cfg.Table),
+		sqlTouch:        fmt.Sprintf("UPDATE %s SET e = ? WHERE k = ? AND (e = 0 OR e > ?)", cfg.Table),
+		sqlInsertNX:     fmt.Sprintf("INSERT INTO %s (k, v, e) VALUES (?,?,?) ON DUPLICATE KEY UPDATE v = IF(e != 0 AND e <= ?, ?, v), e = IF(e != 0 AND e <= ?, ?, e)", cfg.Table),
+		sqlCAS:          fmt.Sprintf("UPDATE %s SET v = ?, e = ? WHERE k = ?", cfg.Table),
+		sqlSelectLock:   fmt.Sprintf("SELECT v, e FROM %s WHERE k=? FOR UPDATE", cfg.Table),
+		sqlUpdate:       fmt.Sprintf("UPDATE %s SET v = ? WHERE k = ?", cfg.Table),
 	}
 
-	store.checkSchema(cfg.Table)
+	if err := store.checkSchema(ctx, cfg.Table); err != nil {
+		return fail(err)
+	}
 
 	// Start garbage collector
-	go store.gcTicker()
+	gcCtx, cancel := context.WithCancel(context.Background())
+	store.cancelGC = cancel
+	go store.gcTicker(gcCtx)
 
-	return store
+	return store, nil
+}
+
+// retry calls fn until it succeeds, cfg.ConnectRetries is exhausted or ctx
+// is done, doubling cfg.ConnectBackoff after every attempt.
+func retry(ctx context.Context, cfg Config, fn func(context.Context) error) error {
+	backoff := cfg.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= cfg.ConnectRetries {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
 }
 
 var noRows = "sql: no rows in result set"
 
 // Get value by key
 func (s *Storage) Get(key string) ([]byte, error) {
+	return s.GetWithContext(context.Background(), key)
+}
+
+// GetWithContext gets value by key, ctx bounds the query
+func (s *Storage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
 	if len(key) <= 0 {
 		return nil, nil
 	}
-	row := s.db.QueryRow(s.sqlSelect, key)
+	row := s.db.QueryRowContext(ctx, s.sqlSelect, key)
 
 	// Add db response to data
 
@@ -136,9 +210,13 @@ func (s *Storage) Get(key string) ([]byte, error) {
 	return data, nil
 }
 
-// Set key with value
 // Set key with value
 func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
+	return s.SetWithContext(context.Background(), key, val, exp)
+}
+
+// SetWithContext sets key with value, ctx bounds the query
+func (s *Storage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
 	// Ain't Nobody Got Time For That
 	if len(key) <= 0 || len(val) <= 0 {
 		return nil
@@ -147,30 +225,61 @@ func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
 	if exp != 0 {
 		expSeconds = time.Now().Add(exp).Unix()
 	}
-	_, err := s.db.Exec(s.sqlInsert, key, val, expSeconds, val, expSeconds)
+	_, err := s.db.ExecContext(ctx, s.sqlInsert, key, val, expSeconds, val, expSeconds)
 	return err
 }
 
 // Delete key by key
 func (s *Storage) Delete(key string) error {
+	return s.DeleteWithContext(context.Background(), key)
+}
+
+// DeleteWithContext deletes key by key, ctx bounds the query
+func (s *Storage) DeleteWithContext(ctx context.Context, key string) error {
 	// Ain't Nobody Got Time For That
 	if len(key) <= 0 {
 		return nil
 	}
-	_, err := s.db.Exec(s.sqlDelete, key)
+	_, err := s.db.ExecContext(ctx, s.sqlDelete, key)
 	return err
 }
 
 // Reset all keys
 func (s *Storage) Reset() error {
-	_, err := s.db.Exec(s.sqlReset)
+	return s.ResetWithContext(context.Background())
+}
+
+// ResetWithContext resets all keys, ctx bounds the query
+func (s *Storage) ResetWithContext(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, s.sqlReset)
 	return err
 }
 
-// Close the database
+// Close stops the gc, waits for a running gc and closes the database,
+// calling it more than once returns the first result
 func (s *Storage) Close() error {
-	s.done <- struct{}{}
-	return s.db.Close()
+	return s.Shutdown(context.Background())
+}
+
+// Shutdown is Close with a deadline, a gc still running when ctx is done is
+// canceled and ctx.Err() is returned. Calling Close or Shutdown more than
+// once returns the first result
+func (s *Storage) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		select {
+		case <-s.gcDone:
+		case <-ctx.Done():
+			s.closeErr = ctx.Err()
+			s.cancelGC()
+			<-s.gcDone
+		}
+		s.cancelGC()
+		if err := s.db.Close(); s.closeErr == nil {
+			s.closeErr = err
+		}
+	})
+	return s.closeErr
 }
 
 // Return database client
@@ -178,8 +287,10 @@ func (s *Storage) Conn() *sql.DB {
 	return s.db
 }
 
-// gcTicker starts the gc ticker
-func (s *Storage) gcTicker() {
+// gcTicker starts the gc ticker, ctx is canceled by Shutdown to abort a
+// running gc
+func (s *Storage) gcTicker(ctx context.Context) {
+	defer close(s.gcDone)
 	ticker := time.NewTicker(s.gcInterval)
 	defer ticker.Stop()
 	for {
@@ -187,25 +298,26 @@ func (s *Storage) gcTicker() {
 		case <-s.done:
 			return
 		case t := <-ticker.C:
-			s.gc(t)
+			s.gc(ctx, t)
 		}
 	}
 }
 
 // gc deletes all expired entries
-func (s *Storage) gc(t time.Time) {
-	_, _ = s.db.Exec(s.sqlGC, t.Unix())
+func (s *Storage) gc(ctx context.Context, t time.Time) {
+	_, _ = s.db.ExecContext(ctx, s.sqlGC, t.Unix())
 }
 
-func (s *Storage) checkSchema(tableName string) {
+func (s *Storage) checkSchema(ctx context.Context, tableName string) error {
 	var data []byte
 
-	row := s.db.QueryRow(fmt.Sprintf(checkSchemaQuery, tableName))
+	row := s.db.QueryRowContext(ctx, fmt.Sprintf(checkSchemaQuery, tableName))
 	if err := row.Scan(&data); err != nil {
-		panic(err)
+		return err
 	}
 
 	if strings.ToLower(string(data)) != "blob" {
 		fmt.Printf(checkSchemaMsg, string(data))
 	}
+	return nil
 }
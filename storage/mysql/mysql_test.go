@@ -1,12 +1,14 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"testing"
 
 	"github.com/gofiber/utils"
+	"go.uber.org/goleak"
 	"time"
 )
 
@@ -17,6 +19,19 @@ var testStore = New(Config{
 	Reset:    true,
 })
 
+// TestMain fails the tests if a storage leaks a goroutine
+func TestMain(m *testing.M) {
+	code := m.Run()
+	_ = testStore.Close()
+	if code == 0 {
+		if err := goleak.Find(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
 func Test_MYSQL_New(t *testing.T) {
 	newConfigStore := New(Config{
 		Database: os.Getenv("MYSQL_DATABASE"),
@@ -45,113 +60,63 @@ func Test_MYSQL_New(t *testing.T) {
 
 	utils.AssertEqual(t, true, newConfigStore.db != nil)
 	newConfigStore.Close()
-}
-
-func Test_MYSQL_Set(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
-
-	err := testStore.Set(key, val, 0)
-	utils.AssertEqual(t, nil, err)
-}
-
-func Test_MYSQL_Set_Override(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
-
-	err := testStore.Set(key, val, 0)
-	utils.AssertEqual(t, nil, err)
-
-	err = testStore.Set(key, val, 0)
-	utils.AssertEqual(t, nil, err)
-}
-
-func Test_MYSQL_Get(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
-
-	err := testStore.Set(key, val, 0)
-	utils.AssertEqual(t, nil, err)
-
-	result, err := testStore.Get(key)
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, val, result)
-}
-
-func Test_MYSQL_Set_Expiration(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-		exp = 1 * time.Second
-	)
-
-	err := testStore.Set(key, val, exp)
-	utils.AssertEqual(t, nil, err)
 
-	time.Sleep(1100 * time.Millisecond)
+	// A failed New leaves the caller's db open
+	db, _ = sql.Open("mysql", dsn)
+	defer db.Close()
+	_, err := NewWithContext(context.Background(), Config{
+		Db:    db,
+		Table: "invalid table",
+	})
+	utils.AssertEqual(t, true, err != nil)
+	utils.AssertEqual(t, nil, db.Ping())
 }
 
-func Test_MYSQL_Get_Expired(t *testing.T) {
+func Test_MYSQL_Atomic(t *testing.T) {
 	var (
-		key = "john"
+		key = "lock"
+		val = []byte("owner-a")
 	)
 
-	result, err := testStore.Get(key)
+	ok, err := testStore.SetIfNotExists(key, val, time.Minute)
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-}
-
-func Test_MYSQL_Get_NotExist(t *testing.T) {
+	utils.AssertEqual(t, true, ok)
 
-	result, err := testStore.Get("notexist")
+	ok, err = testStore.SetIfNotExists(key, []byte("owner-b"), time.Minute)
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-}
+	utils.AssertEqual(t, false, ok)
 
-func Test_MYSQL_Delete(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
-
-	err := testStore.Set(key, val, 0)
+	// MySQL reports 0 affected rows for an update to the same value, the
+	// locked read still reports the swap
+	ok, err = testStore.CompareAndSwap(key, val, val, time.Minute)
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, ok)
 
-	err = testStore.Delete(key)
+	ok, err = testStore.CompareAndSwap(key, []byte("owner-b"), []byte("owner-c"), time.Minute)
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, false, ok)
 
-	result, err := testStore.Get(key)
+	// An expired row that the gc has not deleted yet is replaced by the
+	// ON DUPLICATE KEY UPDATE of SetIfNotExists
+	err = testStore.Set("expired", val, time.Second)
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-}
-
-func Test_MYSQL_Reset(t *testing.T) {
-	var (
-		val = []byte("doe")
-	)
-
-	err := testStore.Set("john1", val, 0)
+	time.Sleep(2 * time.Second)
+	ok, err = testStore.SetIfNotExists("expired", []byte("owner-b"), 0)
 	utils.AssertEqual(t, nil, err)
-
-	err = testStore.Set("john2", val, 0)
+	utils.AssertEqual(t, true, ok)
+	ok, err = testStore.CompareAndSwap("expired", []byte("owner-b"), val, 0)
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, ok)
 
-	err = testStore.Reset()
+	n, err := testStore.Increment("counter", 2, time.Minute)
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, int64(2), n)
 
-	result, err := testStore.Get("john1")
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
+	_, err = testStore.Increment(key, 1, 0)
+	utils.AssertEqual(t, true, err != nil)
 
-	result, err = testStore.Get("john2")
+	err = testStore.DeleteMany([]string{key, "expired", "counter"})
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
 }
 
 func Test_MYSQL_GC(t *testing.T) {
@@ -163,7 +128,7 @@ func Test_MYSQL_GC(t *testing.T) {
 	err := testStore.Set("john", testVal, time.Nanosecond)
 	utils.AssertEqual(t, nil, err)
 
-	testStore.gc(time.Now())
+	testStore.gc(context.Background(), time.Now())
 	row := testStore.db.QueryRow(testStore.sqlSelect, "john")
 	err = row.Scan(nil, nil)
 	utils.AssertEqual(t, sql.ErrNoRows, err)
@@ -172,7 +137,7 @@ func Test_MYSQL_GC(t *testing.T) {
 	err = testStore.Set("john", testVal, 0)
 	utils.AssertEqual(t, nil, err)
 
-	testStore.gc(time.Now())
+	testStore.gc(context.Background(), time.Now())
 	val, err := testStore.Get("john")
 	utils.AssertEqual(t, nil, err)
 	utils.AssertEqual(t, testVal, val)
@@ -190,6 +155,21 @@ func Test_MYSQL_Non_UTF8(t *testing.T) {
 	utils.AssertEqual(t, val, result)
 }
 
+func Test_MYSQL_Shutdown(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	for i := 0; i < 10; i++ {
+		store := New(Config{
+			Database: os.Getenv("MYSQL_DATABASE"),
+			Username: os.Getenv("MYSQL_USERNAME"),
+			Password: os.Getenv("MYSQL_PASSWORD"),
+		})
+		utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+		utils.AssertEqual(t, nil, store.Shutdown(context.Background()))
+		utils.AssertEqual(t, nil, store.Close())
+	}
+}
+
 func Test_MYSQL_Close(t *testing.T) {
 	utils.AssertEqual(t, nil, testStore.Close())
 }
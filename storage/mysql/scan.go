@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// defaultPageSize is used by Scan when pageSize is not positive
+const defaultPageSize = 100
+
+// likeEscaper escapes the LIKE wildcards of a prefix, `!` is the escape
+// character of sqlScan
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// Scan calls fn with the unexpired keys starting with prefix in sorted
+// order, at most pageSize keys at a time. Pages are read with
+// `LIKE BINARY 'prefix%'`, case-sensitive whatever the collation of the
+// table, after the last key of the previous page, so fn may modify the
+// storage.
+func (s *Storage) Scan(ctx context.Context, prefix string, pageSize int, fn func(keys []string) error) error {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	pattern := likeEscaper.Replace(prefix) + "%"
+
+	var last string
+	for {
+		keys, err := s.scanPage(ctx, pattern, last, pageSize)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		if err := fn(keys); err != nil {
+			return err
+		}
+		if len(keys) < pageSize {
+			return nil
+		}
+		last = keys[len(keys)-1]
+	}
+}
+
+func (s *Storage) scanPage(ctx context.Context, pattern, after string, limit int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.sqlScan, pattern, after, time.Now().Unix(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]string, 0, limit)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// DeletePrefix deletes every key starting with prefix in a single
+// `DELETE ... LIKE BINARY 'prefix%'`, so the prefix is case-sensitive
+// whatever the collation of the table
+func (s *Storage) DeletePrefix(ctx context.Context, prefix string) error {
+	_, err := s.db.ExecContext(ctx, s.sqlDeletePrefix, likeEscaper.Replace(prefix)+"%")
+	return err
+}
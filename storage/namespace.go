@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Namespace returns a Storage that prefixes every key of s with prefix, so
+// several services can share one backend. Reset deletes only the keys of
+// the namespace, see DeletePrefix, and Close closes s.
+//
+// The result implements StorageWithContext and Batcher, falling back like
+// WithContext and Batch, and each of Scanner, Expirer and Atomic only if s
+// does. It is a PrefixDeleter if s is a PrefixDeleter or a Scanner, see
+// DeletePrefix, otherwise Reset returns ErrUnsupported.
+func Namespace(s Storage, prefix string) Storage {
+	n := &namespace{s: s, sc: WithContext(s), b: Batch(s), prefix: prefix}
+
+	const (
+		scanner = 1 << iota
+		prefixDeleter
+		expirer
+		atomic
+	)
+	var has int
+	if _, ok := s.(Scanner); ok {
+		has |= scanner | prefixDeleter
+	}
+	if _, ok := s.(PrefixDeleter); ok {
+		has |= prefixDeleter
+	}
+	if _, ok := s.(Expirer); ok {
+		has |= expirer
+	}
+	if _, ok := s.(Atomic); ok {
+		has |= atomic
+	}
+
+	// One type per combination, so type assertions tell what s supports
+	sc, d := (*namespaceScanner)(n), (*namespacePrefixDeleter)(n)
+	e, a := (*namespaceExpirer)(n), (*namespaceAtomic)(n)
+	switch has {
+	case prefixDeleter:
+		return struct {
+			*namespace
+			*namespacePrefixDeleter
+		}{n, d}
+	case scanner | prefixDeleter:
+		return struct {
+			*namespace
+			*namespaceScanner
+			*namespacePrefixDeleter
+		}{n, sc, d}
+	case expirer:
+		return struct {
+			*namespace
+			*namespaceExpirer
+		}{n, e}
+	case prefixDeleter | expirer:
+		return struct {
+			*namespace
+			*namespacePrefixDeleter
+			*namespaceExpirer
+		}{n, d, e}
+	case scanner | prefixDeleter | expirer:
+		return struct {
+			*namespace
+			*namespaceScanner
+			*namespacePrefixDeleter
+			*namespaceExpirer
+		}{n, sc, d, e}
+	case atomic:
+		return struct {
+			*namespace
+			*namespaceAtomic
+		}{n, a}
+	case prefixDeleter | atomic:
+		return struct {
+			*namespace
+			*namespacePrefixDeleter
+			*namespaceAtomic
+		}{n, d, a}
+	case scanner | prefixDeleter | atomic:
+		return struct {
+			*namespace
+			*namespaceScanner
+			*namespacePrefixDeleter
+			*namespaceAtomic
+		}{n, sc, d, a}
+	case expirer | atomic:
+		return struct {
+			*namespace
+			*namespaceExpirer
+			*namespaceAtomic
+		}{n, e, a}
+	case prefixDeleter | expirer | atomic:
+		return struct {
+			*namespace
+			*namespacePrefixDeleter
+			*namespaceExpirer
+			*namespaceAtomic
+		}{n, d, e, a}
+	case scanner | prefixDeleter | expirer | atomic:
+		return struct {
+			*namespace
+			*namespaceScanner
+			*namespacePrefixDeleter
+			*namespaceExpirer
+			*namespaceAtomic
+		}{n, sc, d, e, a}
+	}
+	return n
+}
+
+type namespace struct {
+	s      Storage
+	sc     StorageWithContext
+	b      Batcher
+	prefix string
+}
+
+// The optional interfaces of a namespace, Namespace embeds those of s
+type (
+	namespaceScanner       namespace
+	namespacePrefixDeleter namespace
+	namespaceExpirer       namespace
+	namespaceAtomic        namespace
+)
+
+func (n *namespace) Get(key string) ([]byte, error) {
+	return n.GetWithContext(context.Background(), key)
+}
+
+func (n *namespace) Set(key string, val []byte, exp time.Duration) error {
+	return n.SetWithContext(context.Background(), key, val, exp)
+}
+
+func (n *namespace) Delete(key string) error {
+	return n.DeleteWithContext(context.Background(), key)
+}
+
+func (n *namespace) Reset() error {
+	return n.ResetWithContext(context.Background())
+}
+
+func (n *namespace) Close() error {
+	return n.s.Close()
+}
+
+func (n *namespace) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	if len(key) <= 0 {
+		return nil, nil
+	}
+	return n.sc.GetWithContext(ctx, n.prefix+key)
+}
+
+func (n *namespace) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(val) <= 0 {
+		return nil
+	}
+	return n.sc.SetWithContext(ctx, n.prefix+key, val, exp)
+}
+
+func (n *namespace) DeleteWithContext(ctx context.Context, key string) error {
+	if len(key) <= 0 {
+		return nil
+	}
+	return n.sc.DeleteWithContext(ctx, n.prefix+key)
+}
+
+func (n *namespace) ResetWithContext(ctx context.Context) error {
+	return DeletePrefix(ctx, n.s, n.prefix)
+}
+
+func (n *namespace) GetMany(keys []string) (map[string][]byte, error) {
+	values, err := n.b.GetMany(n.keys(keys))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]byte, len(values))
+	for key, val := range values {
+		result[strings.TrimPrefix(key, n.prefix)] = val
+	}
+	return result, nil
+}
+
+func (n *namespace) SetMany(entries map[string]Entry) error {
+	prefixed := make(map[string]Entry, len(entries))
+	for key, e := range entries {
+		if len(key) > 0 {
+			prefixed[n.prefix+key] = e
+		}
+	}
+	return n.b.SetMany(prefixed)
+}
+
+func (n *namespace) DeleteMany(keys []string) error {
+	return n.b.DeleteMany(n.keys(keys))
+}
+
+func (n *namespaceScanner) Scan(ctx context.Context, prefix string, pageSize int, fn func(keys []string) error) error {
+	return n.s.(Scanner).Scan(ctx, n.prefix+prefix, pageSize, func(keys []string) error {
+		for i, key := range keys {
+			keys[i] = strings.TrimPrefix(key, n.prefix)
+		}
+		return fn(keys)
+	})
+}
+
+func (n *namespacePrefixDeleter) DeletePrefix(ctx context.Context, prefix string) error {
+	return DeletePrefix(ctx, n.s, n.prefix+prefix)
+}
+
+func (n *namespaceExpirer) TTL(key string) (time.Duration, bool, error) {
+	return n.s.(Expirer).TTL(n.prefix + key)
+}
+
+func (n *namespaceExpirer) Touch(key string, exp time.Duration) error {
+	return n.s.(Expirer).Touch(n.prefix+key, exp)
+}
+
+func (n *namespaceAtomic) SetIfNotExists(key string, val []byte, exp time.Duration) (bool, error) {
+	if len(key) <= 0 {
+		return false, nil
+	}
+	return n.s.(Atomic).SetIfNotExists(n.prefix+key, val, exp)
+}
+
+func (n *namespaceAtomic) CompareAndSwap(key string, old, new []byte, exp time.Duration) (bool, error) {
+	if len(key) <= 0 {
+		return false, nil
+	}
+	return n.s.(Atomic).CompareAndSwap(n.prefix+key, old, new, exp)
+}
+
+func (n *namespaceAtomic) Increment(key string, delta int64, exp time.Duration) (int64, error) {
+	if len(key) <= 0 {
+		return 0, nil
+	}
+	return n.s.(Atomic).Increment(n.prefix+key, delta, exp)
+}
+
+// keys returns keys with the prefix, empty keys are dropped
+func (n *namespace) keys(keys []string) []string {
+	prefixed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if len(key) > 0 {
+			prefixed = append(prefixed, n.prefix+key)
+		}
+	}
+	return prefixed
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// scanStorage is a slowStorage that is a Scanner
+type scanStorage struct {
+	*slowStorage
+}
+
+func (s scanStorage) Scan(ctx context.Context, prefix string, pageSize int, fn func(keys []string) error) error {
+	var keys []string
+	for key := range s.db {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		return nil
+	}
+	return fn(keys)
+}
+
+func TestNamespace(t *testing.T) {
+	store := scanStorage{&slowStorage{db: map[string][]byte{}}}
+	a := Namespace(store, "svc-a:")
+	b := Namespace(store, "svc-b:")
+
+	for _, s := range []Storage{a, b} {
+		if err := s.Set("john", []byte("doe"), 0); err != nil {
+			t.Fatalf("Expected %v, but got %v", nil, err)
+		}
+	}
+	if err := a.Set("", []byte("doe"), 0); err != nil {
+		t.Fatalf("Expected %v, but got %v", nil, err)
+	}
+	expected := map[string][]byte{"svc-a:john": []byte("doe"), "svc-b:john": []byte("doe")}
+	if !reflect.DeepEqual(store.db, expected) {
+		t.Errorf("Expected %v, but got %v", expected, store.db)
+	}
+
+	values, err := a.(Batcher).GetMany([]string{"john", "jane"})
+	if err != nil || !reflect.DeepEqual(values, map[string][]byte{"john": []byte("doe")}) {
+		t.Errorf("Expected %v, but got %v %v", "john", values, err)
+	}
+
+	keys, err := Keys(context.Background(), a.(Scanner), "")
+	if err != nil || !reflect.DeepEqual(keys, []string{"john"}) {
+		t.Errorf("Expected %v, but got %v %v", []string{"john"}, keys, err)
+	}
+
+	// Reset deletes only the keys of the namespace
+	if err = a.Reset(); err != nil {
+		t.Fatalf("Expected %v, but got %v", nil, err)
+	}
+	expected = map[string][]byte{"svc-b:john": []byte("doe")}
+	if !reflect.DeepEqual(store.db, expected) {
+		t.Errorf("Expected %v, but got %v", expected, store.db)
+	}
+
+	// Only the optional interfaces of store are implemented
+	if _, ok := a.(PrefixDeleter); !ok {
+		t.Errorf("Expected %v, but got %v", true, ok)
+	}
+	if _, ok := a.(Expirer); ok {
+		t.Errorf("Expected %v, but got %v", false, ok)
+	}
+	if _, ok := a.(Atomic); ok {
+		t.Errorf("Expected %v, but got %v", false, ok)
+	}
+	if _, ok := Namespace(&slowStorage{}, "svc-a:").(Scanner); ok {
+		t.Errorf("Expected %v, but got %v", false, ok)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	store := &slowStorage{db: map[string][]byte{"svc-a:john": []byte("doe")}}
+	if err := DeletePrefix(context.Background(), store, "svc-a:"); err != ErrUnsupported {
+		t.Errorf("Expected %v, but got %v", ErrUnsupported, err)
+	}
+
+	if err := DeletePrefix(context.Background(), scanStorage{store}, "svc-a:"); err != nil {
+		t.Fatalf("Expected %v, but got %v", nil, err)
+	}
+	if len(store.db) != 0 {
+		t.Errorf("Expected %v, but got %v", 0, len(store.db))
+	}
+}
@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// casScript sets KEYS[1] to ARGV[2] with a PX of ARGV[3] milliseconds, 0
+// means no expiration, if its value is ARGV[1]
+var casScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// incrScript adds ARGV[1] to KEYS[1] and sets a PX of ARGV[2] milliseconds
+// if the key did not exist
+var incrScript = redis.NewScript(`
+local exists = redis.call("EXISTS", KEYS[1])
+local n = redis.call("INCRBY", KEYS[1], ARGV[1])
+if exists == 0 and tonumber(ARGV[2]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return n
+`)
+
+// SetIfNotExists sets key with value if key does not exist, using SET NX.
+// It reports whether the value was set
+func (s *Storage) SetIfNotExists(key string, val []byte, exp time.Duration) (bool, error) {
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(val) <= 0 {
+		return false, nil
+	}
+	return s.db.SetNX(context.Background(), key, val, exp).Result()
+}
+
+// CompareAndSwap sets key to new if its value is old, a nil old means key
+// must not exist. It reports whether the value was swapped
+func (s *Storage) CompareAndSwap(key string, old, new []byte, exp time.Duration) (bool, error) {
+	if old == nil {
+		return s.SetIfNotExists(key, new, exp)
+	}
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(new) <= 0 {
+		return false, nil
+	}
+	n, err := casScript.Run(context.Background(), s.db, []string{key}, old, new, exp.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// Increment adds delta to the counter at key with INCRBY and returns the
+// new value, a missing key starts at 0 and expires after exp
+func (s *Storage) Increment(key string, delta int64, exp time.Duration) (int64, error) {
+	if len(key) <= 0 {
+		return 0, nil
+	}
+	return incrScript.Run(context.Background(), s.db, []string{key}, delta, exp.Milliseconds()).Int64()
+}
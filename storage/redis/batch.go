@@ -0,0 +1,95 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/20326/flexbox/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// GetMany gets the values of keys with a single MGET, missing keys are left
+// out. In a cluster, where MGET fails across slots, the keys are read with
+// a pipeline of GETs.
+func (s *Storage) GetMany(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	if cluster, ok := s.db.(*redis.ClusterClient); ok {
+		cmds := make([]*redis.StringCmd, len(keys))
+		_, err := cluster.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = pipe.Get(context.Background(), key)
+			}
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		for i, cmd := range cmds {
+			val, err := cmd.Bytes()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			values[keys[i]] = val
+		}
+		return values, nil
+	}
+
+	res, err := s.db.MGet(context.Background(), keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range res {
+		if v, ok := v.(string); ok {
+			values[keys[i]] = []byte(v)
+		}
+	}
+	return values, nil
+}
+
+// SetMany sets all entries in one transactional pipeline. In a cluster,
+// where MULTI fails across slots, the pipeline is not transactional.
+func (s *Storage) SetMany(entries map[string]storage.Entry) error {
+	set := func(pipe redis.Pipeliner) error {
+		for key, e := range entries {
+			// Ain't Nobody Got Time For That
+			if len(key) <= 0 || len(e.Value) <= 0 {
+				continue
+			}
+			pipe.Set(context.Background(), key, e.Value, e.Exp)
+		}
+		return nil
+	}
+
+	var err error
+	if cluster, ok := s.db.(*redis.ClusterClient); ok {
+		_, err = cluster.Pipelined(context.Background(), set)
+	} else {
+		_, err = s.db.TxPipelined(context.Background(), set)
+	}
+	return err
+}
+
+// DeleteMany deletes all keys with a single DEL. In a cluster, where DEL
+// fails across slots, the keys are deleted with a pipeline of DELs.
+func (s *Storage) DeleteMany(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if cluster, ok := s.db.(*redis.ClusterClient); ok {
+		_, err := cluster.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Del(context.Background(), key)
+			}
+			return nil
+		})
+		return err
+	}
+	return s.db.Del(context.Background(), keys...).Err()
+}
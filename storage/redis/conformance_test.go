@@ -0,0 +1,14 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/20326/flexbox/storage"
+	"github.com/20326/flexbox/storage/storagetest"
+)
+
+func Test_Redis_Conformance(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Storage {
+		return New()
+	})
+}
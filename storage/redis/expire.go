@@ -0,0 +1,30 @@
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+// TTL returns the time left before key expires with millisecond
+// precision, ok is false if key does not exist
+func (s *Storage) TTL(key string) (time.Duration, bool, error) {
+	ttl, err := s.db.PTTL(context.Background(), key).Result()
+	if err != nil {
+		return 0, false, err
+	}
+	switch {
+	case ttl == -2: // key does not exist
+		return 0, false, nil
+	case ttl < 0: // key has no expiration
+		return 0, true, nil
+	}
+	return ttl, true, nil
+}
+
+// Touch sets the expiration of key to exp from now, 0 means no expiration
+func (s *Storage) Touch(key string, exp time.Duration) error {
+	if exp == 0 {
+		return s.db.Persist(context.Background(), key).Err()
+	}
+	return s.db.PExpire(context.Background(), key, exp).Err()
+}
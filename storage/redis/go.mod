@@ -3,6 +3,7 @@ module storage/redis
 go 1.18
 
 require (
+	github.com/20326/flexbox v0.0.0
 	github.com/gofiber/utils v1.0.1
 	github.com/redis/go-redis/v9 v9.0.3
 )
@@ -11,3 +12,5 @@ require (
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 )
+
+replace github.com/20326/flexbox => ../..
@@ -2,6 +2,7 @@ package redis
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -11,10 +12,23 @@ import (
 
 type Storage struct {
 	db redis.UniversalClient
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
-// New creates a new redis storage
+// New creates a new redis storage, it panics if redis cannot be reached
 func New(config ...Config) *Storage {
+	store, err := NewWithContext(context.Background(), config...)
+	if err != nil {
+		panic(err)
+	}
+	return store
+}
+
+// NewWithContext creates a new redis storage and returns an error instead
+// of panicking. ctx bounds connecting to redis, including retries.
+func NewWithContext(ctx context.Context, config ...Config) (*Storage, error) {
 	// Set default config
 	cfg := configDefault(config...)
 
@@ -43,32 +57,77 @@ func New(config ...Config) *Storage {
 		//		IdleCheckFrequency: cfg.IdleCheckFrequency,
 	}
 
+	// The URL overrides the address and credentials
+	if cfg.URL != "" {
+		opt, err := redis.ParseURL(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		options.Addrs = []string{opt.Addr}
+		options.DB = opt.DB
+		options.Username = opt.Username
+		options.Password = opt.Password
+		if options.TLSConfig == nil {
+			options.TLSConfig = opt.TLSConfig
+		}
+	}
+
 	db := redis.NewUniversalClient(options)
 
 	// Test connection
-	if err := db.Ping(context.Background()).Err(); err != nil {
-		panic(err)
+	if err := retry(ctx, cfg, func(ctx context.Context) error {
+		return db.Ping(ctx).Err()
+	}); err != nil {
+		_ = db.Close()
+		return nil, err
 	}
 
 	// Empty collection if Clear is true
 	if cfg.Reset {
-		if err := db.FlushDB(context.Background()).Err(); err != nil {
-			panic(err)
+		if err := db.FlushDB(ctx).Err(); err != nil {
+			_ = db.Close()
+			return nil, err
 		}
 	}
 
 	// Create new store
 	return &Storage{
 		db: db,
+	}, nil
+}
+
+// retry calls fn until it succeeds, cfg.ConnectRetries is exhausted or ctx
+// is done, doubling cfg.ConnectBackoff after every attempt.
+func retry(ctx context.Context, cfg Config, fn func(context.Context) error) error {
+	backoff := cfg.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= cfg.ConnectRetries {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
 	}
 }
 
 // Get value by key
 func (s *Storage) Get(key string) ([]byte, error) {
+	return s.GetWithContext(context.Background(), key)
+}
+
+// GetWithContext gets value by key, ctx bounds the request
+func (s *Storage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
 	if len(key) <= 0 {
 		return nil, nil
 	}
-	val, err := s.db.Get(context.Background(), key).Bytes()
+	val, err := s.db.Get(ctx, key).Bytes()
 	if err == redis.Nil {
 		return nil, nil
 	}
@@ -77,30 +136,48 @@ func (s *Storage) Get(key string) ([]byte, error) {
 
 // Set key with value
 func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
+	return s.SetWithContext(context.Background(), key, val, exp)
+}
+
+// SetWithContext sets key with value, ctx bounds the request
+func (s *Storage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
 	// Ain't Nobody Got Time For That
 	if len(key) <= 0 || len(val) <= 0 {
 		return nil
 	}
-	return s.db.Set(context.Background(), key, val, exp).Err()
+	return s.db.Set(ctx, key, val, exp).Err()
 }
 
 // Delete key by key
 func (s *Storage) Delete(key string) error {
+	return s.DeleteWithContext(context.Background(), key)
+}
+
+// DeleteWithContext deletes key by key, ctx bounds the request
+func (s *Storage) DeleteWithContext(ctx context.Context, key string) error {
 	// Ain't Nobody Got Time For That
 	if len(key) <= 0 {
 		return nil
 	}
-	return s.db.Del(context.Background(), key).Err()
+	return s.db.Del(ctx, key).Err()
 }
 
 // Reset all keys
 func (s *Storage) Reset() error {
-	return s.db.FlushDB(context.Background()).Err()
+	return s.ResetWithContext(context.Background())
+}
+
+// ResetWithContext resets all keys, ctx bounds the request
+func (s *Storage) ResetWithContext(ctx context.Context) error {
+	return s.db.FlushDB(ctx).Err()
 }
 
-// Close the database
+// Close the database, calling it more than once returns the first result
 func (s *Storage) Close() error {
-	return s.db.Close()
+	s.closeOnce.Do(func() {
+		s.closeErr = s.db.Close()
+	})
+	return s.closeErr
 }
 
 // Conn Return database client
@@ -1,122 +1,105 @@
 package redis
 
 import (
+	"context"
 	"crypto/tls"
 	"log"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/20326/flexbox/storage"
 	"github.com/gofiber/utils"
+	"github.com/redis/go-redis/v9"
 )
 
 var testStore = New(Config{
 	Reset: true,
 })
 
-func Test_Redis_Set(t *testing.T) {
+func Test_Redis_Atomic(t *testing.T) {
 	var (
-		key = "john"
-		val = []byte("doe")
+		key = "lock"
+		val = []byte("owner-a")
 	)
 
-	err := testStore.Set(key, val, 0)
+	// A nil old is SET NX
+	ok, err := testStore.CompareAndSwap(key, nil, val, 0)
 	utils.AssertEqual(t, nil, err)
-}
-
-func Test_Redis_Set_Override(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
+	utils.AssertEqual(t, true, ok)
 
-	err := testStore.Set(key, val, 0)
+	ok, err = testStore.CompareAndSwap(key, nil, []byte("owner-b"), 0)
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, false, ok)
 
-	err = testStore.Set(key, val, 0)
+	ok, err = testStore.CompareAndSwap(key, []byte("owner-b"), []byte("owner-c"), 0)
 	utils.AssertEqual(t, nil, err)
-}
+	utils.AssertEqual(t, false, ok)
 
-func Test_Redis_Get(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
-
-	err := testStore.Set(key, val, 0)
+	// The script compares and sets in one step and sets the PX of exp
+	ok, err = testStore.CompareAndSwap(key, val, val, time.Minute)
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, ok)
 
-	result, err := testStore.Get(key)
+	ttl, ok, err := testStore.TTL(key)
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, val, result)
-}
+	utils.AssertEqual(t, true, ok)
+	utils.AssertEqual(t, true, ttl > 0 && ttl <= time.Minute)
 
-func Test_Redis_Set_Expiration(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-		exp = 1 * time.Second
-	)
-
-	err := testStore.Set(key, val, exp)
-	utils.AssertEqual(t, nil, err)
-
-	time.Sleep(1100 * time.Millisecond)
-}
-
-func Test_Redis_Get_Expired(t *testing.T) {
-	var (
-		key = "john"
-	)
-
-	result, err := testStore.Get(key)
+	// A swap without exp drops the expiration
+	ok, err = testStore.CompareAndSwap(key, val, []byte("owner-b"), 0)
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-}
+	utils.AssertEqual(t, true, ok)
 
-func Test_Redis_Get_NotExist(t *testing.T) {
-	result, err := testStore.Get("notexist")
+	ttl, ok, err = testStore.TTL(key)
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-}
-
-func Test_Redis_Delete(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
+	utils.AssertEqual(t, true, ok)
+	utils.AssertEqual(t, time.Duration(0), ttl)
 
-	err := testStore.Set(key, val, 0)
+	n, err := testStore.Increment("counter", 2, time.Minute)
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, int64(2), n)
 
-	err = testStore.Delete(key)
-	utils.AssertEqual(t, nil, err)
+	_, err = testStore.Increment(key, 1, 0)
+	utils.AssertEqual(t, true, err != nil)
 
-	result, err := testStore.Get(key)
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
+	utils.AssertEqual(t, nil, testStore.DeleteMany([]string{key, "counter"}))
 }
 
-func Test_Redis_Reset(t *testing.T) {
-	var (
-		val = []byte("doe")
-	)
-
-	err := testStore.Set("john1", val, 0)
-	utils.AssertEqual(t, nil, err)
-
-	err = testStore.Set("john2", val, 0)
-	utils.AssertEqual(t, nil, err)
+func Test_Redis_Batch_Cluster(t *testing.T) {
+	addrs := os.Getenv("REDIS_CLUSTER_ADDRS")
+	if addrs == "" {
+		t.Skip("REDIS_CLUSTER_ADDRS is not set")
+	}
+	store := New(Config{
+		Addrs: strings.Split(addrs, ","),
+		Reset: true,
+	})
+	_, ok := store.Conn().(*redis.ClusterClient)
+	utils.AssertEqual(t, true, ok)
+
+	// The keys hash to different slots, MGET, MULTI and DEL would fail
+	keys := []string{"john", "jane", "doe", "smith"}
+	entries := make(map[string]storage.Entry, len(keys))
+	for _, key := range keys {
+		entries[key] = storage.Entry{Value: []byte(key)}
+	}
+	utils.AssertEqual(t, nil, store.SetMany(entries))
 
-	err = testStore.Reset()
+	values, err := store.GetMany(append(keys, "missing"))
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, len(keys), len(values))
+	for _, key := range keys {
+		utils.AssertEqual(t, []byte(key), values[key])
+	}
 
-	result, err := testStore.Get("john1")
+	utils.AssertEqual(t, nil, store.DeleteMany(keys))
+	values, err = store.GetMany(keys)
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
+	utils.AssertEqual(t, 0, len(values))
 
-	result, err = testStore.Get("john2")
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
+	utils.AssertEqual(t, nil, store.Close())
 }
 
 func Test_Redis_Close(t *testing.T) {
@@ -191,3 +174,24 @@ func Test_Redis_Initalize_WithURL_TLS(t *testing.T) {
 
 	utils.AssertEqual(t, nil, testStoreUrl.Close())
 }
+
+func Test_Redis_NewWithContext_Unavailable(t *testing.T) {
+	start := time.Now()
+	store, err := NewWithContext(context.Background(), Config{
+		Addrs:          []string{"127.0.0.1:1"},
+		ConnectRetries: 2,
+		ConnectBackoff: 10 * time.Millisecond,
+	})
+	utils.AssertEqual(t, true, err != nil)
+	utils.AssertEqual(t, true, store == nil)
+	utils.AssertEqual(t, true, time.Since(start) >= 30*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = NewWithContext(ctx, Config{
+		Addrs:          []string{"127.0.0.1:1"},
+		ConnectRetries: 100,
+		ConnectBackoff: 10 * time.Millisecond,
+	})
+	utils.AssertEqual(t, true, err != nil)
+}
@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultPageSize is used by Scan when pageSize is not positive
+const defaultPageSize = 100
+
+// globEscaper escapes the glob characters of a prefix in a SCAN pattern
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// Scan calls fn with the keys starting with prefix, using SCAN with
+// pageSize as COUNT hint, never KEYS. Pages may be smaller or larger than
+// pageSize and, like SCAN itself, may return a key more than once. In a
+// cluster every master is scanned.
+func (s *Storage) Scan(ctx context.Context, prefix string, pageSize int, fn func(keys []string) error) error {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	match := globEscaper.Replace(prefix) + "*"
+
+	if cluster, ok := s.db.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scan(ctx, client, match, pageSize, fn)
+		})
+	}
+	return scan(ctx, s.db, match, pageSize, fn)
+}
+
+func scan(ctx context.Context, db redis.Cmdable, match string, pageSize int, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := db.Scan(ctx, cursor, match, int64(pageSize)).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// DeletePrefix deletes every key starting with prefix, scanning with SCAN
+// and unlinking each page in a pipeline. In a cluster every master is
+// scanned.
+func (s *Storage) DeletePrefix(ctx context.Context, prefix string) error {
+	match := globEscaper.Replace(prefix) + "*"
+
+	if cluster, ok := s.db.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return deletePrefix(ctx, client, match)
+		})
+	}
+	return deletePrefix(ctx, s.db, match)
+}
+
+func deletePrefix(ctx context.Context, db redis.Cmdable, match string) error {
+	return scan(ctx, db, match, defaultPageSize, func(keys []string) error {
+		// Unlink key by key, a cluster rejects multi-key commands across slots
+		_, err := db.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Unlink(ctx, key)
+			}
+			return nil
+		})
+		return err
+	})
+}
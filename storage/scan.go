@@ -0,0 +1,53 @@
+package storage
+
+import "context"
+
+// Scanner is implemented by storages that can enumerate their keys.
+type Scanner interface {
+	// Scan calls fn with the keys starting with prefix, at most pageSize
+	// keys at a time. An empty prefix matches every key, expired keys are
+	// skipped. Scanning stops at the first error of fn or when ctx is done.
+	//
+	// Keys may be deleted in fn. Keys that are added or removed during the
+	// scan may or may not be seen, and some backends may return a key more
+	// than once.
+	Scan(ctx context.Context, prefix string, pageSize int, fn func(keys []string) error) error
+}
+
+// PrefixDeleter is implemented by storages that delete the keys with a
+// prefix natively.
+type PrefixDeleter interface {
+	// DeletePrefix deletes every key starting with prefix, an empty prefix
+	// deletes every key.
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// DeletePrefix deletes every key of s that starts with prefix. Storages
+// that are not a PrefixDeleter are scanned and their keys deleted page by
+// page, ErrUnsupported is returned if s is not a Scanner either.
+func DeletePrefix(ctx context.Context, s Storage, prefix string) error {
+	if d, ok := s.(PrefixDeleter); ok {
+		return d.DeletePrefix(ctx, prefix)
+	}
+	sc, ok := s.(Scanner)
+	if !ok {
+		return ErrUnsupported
+	}
+	b := Batch(s)
+	return sc.Scan(ctx, prefix, 0, func(keys []string) error {
+		return b.DeleteMany(keys)
+	})
+}
+
+// Keys returns every key of s that starts with prefix.
+func Keys(ctx context.Context, s Scanner, prefix string) ([]string, error) {
+	var keys []string
+	err := s.Scan(ctx, prefix, 0, func(page []string) error {
+		keys = append(keys, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type keyScanner []string
+
+func (s keyScanner) Scan(ctx context.Context, prefix string, pageSize int, fn func(keys []string) error) error {
+	var page []string
+	for _, key := range s {
+		if strings.HasPrefix(key, prefix) {
+			page = append(page, key)
+		}
+	}
+	return fn(page)
+}
+
+func TestKeys(t *testing.T) {
+	keys, err := Keys(context.Background(), keyScanner{"tenant-a:1", "tenant-b:1", "tenant-a:2"}, "tenant-a:")
+	sort.Strings(keys)
+	expected := []string{"tenant-a:1", "tenant-a:2"}
+	if err != nil || !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected %v, but got %v %v", expected, keys, err)
+	}
+}
@@ -0,0 +1,89 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SetIfNotExists sets key with value if key does not exist, using
+// `INSERT ... ON CONFLICT`. It reports whether the value was set
+func (s *Storage) SetIfNotExists(key string, val []byte, exp time.Duration) (bool, error) {
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(val) <= 0 {
+		return false, nil
+	}
+	now := time.Now()
+	res, err := s.db.Exec(s.sqlInsertNX, key, val, expiration(now, exp), now.Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CompareAndSwap sets key to new if its value is old, a nil old means key
+// must not exist. It reports whether the value was swapped
+func (s *Storage) CompareAndSwap(key string, old, new []byte, exp time.Duration) (bool, error) {
+	if old == nil {
+		return s.SetIfNotExists(key, new, exp)
+	}
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(new) <= 0 {
+		return false, nil
+	}
+	now := time.Now()
+	res, err := s.db.Exec(s.sqlCAS, new, expiration(now, exp), key, old, now.Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Increment adds delta to the counter at key and returns the new value, a
+// missing key starts at 0 and expires after exp
+func (s *Storage) Increment(key string, delta int64, exp time.Duration) (int64, error) {
+	if len(key) <= 0 {
+		return 0, nil
+	}
+
+	var n int64
+	err := s.inTx(func(tx *sql.Tx) error {
+		// Create the counter if it does not exist, the write locks the
+		// database until the transaction ends
+		now := time.Now()
+		if _, err := tx.Exec(s.sqlInsertNX, key, []byte("0"), expiration(now, exp), now.Unix()); err != nil {
+			return err
+		}
+
+		var (
+			data []byte
+			e    int64
+		)
+		if err := tx.QueryRow(s.sqlSelect, key).Scan(&data, &e); err != nil {
+			return err
+		}
+		value, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return fmt.Errorf("sqlite3: value of %q is not an integer", key)
+		}
+
+		n = value + delta
+		_, err = tx.Exec(s.sqlUpdate, strconv.AppendInt(nil, n, 10), key)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// expiration returns the expiration column of exp, 0 means no expiration
+func expiration(now time.Time, exp time.Duration) int64 {
+	if exp == 0 {
+		return 0
+	}
+	return now.Add(exp).Unix()
+}
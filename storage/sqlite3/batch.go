@@ -0,0 +1,124 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/20326/flexbox/storage"
+)
+
+// batchSize is the maximum number of keys in one `IN (...)` query, below
+// the default SQLITE_MAX_VARIABLE_NUMBER of older SQLite versions
+const batchSize = 500
+
+// GetMany gets the values of keys with one query per 500 keys, missing and
+// expired keys are left out
+func (s *Storage) GetMany(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	now := time.Now().Unix()
+	for _, chunk := range chunks(keys) {
+		rows, err := s.db.Query(fmt.Sprintf(s.sqlSelectMany, placeholders(len(chunk))), chunk...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var (
+				key  string
+				data []byte
+				exp  int64
+			)
+			if err := rows.Scan(&key, &data, &exp); err != nil {
+				_ = rows.Close()
+				return nil, err
+			}
+			if exp != 0 && exp <= now {
+				continue
+			}
+			values[key] = data
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// SetMany sets all entries in one transaction
+func (s *Storage) SetMany(entries map[string]storage.Entry) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(s.sqlInsert)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for key, e := range entries {
+			// Ain't Nobody Got Time For That
+			if len(key) <= 0 || len(e.Value) <= 0 {
+				continue
+			}
+			var expSeconds int64
+			if e.Exp != 0 {
+				expSeconds = time.Now().Add(e.Exp).Unix()
+			}
+			if _, err := stmt.Exec(key, e.Value, expSeconds); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteMany deletes all keys in one transaction
+func (s *Storage) DeleteMany(keys []string) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		for _, chunk := range chunks(keys) {
+			if _, err := tx.Exec(fmt.Sprintf(s.sqlDeleteMany, placeholders(len(chunk))), chunk...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// inTx runs fn in a transaction and commits it if fn succeeds
+func (s *Storage) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// chunks splits keys into query arguments of at most batchSize keys
+func chunks(keys []string) [][]interface{} {
+	var out [][]interface{}
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > batchSize {
+			n = batchSize
+		}
+		chunk := make([]interface{}, n)
+		for i, key := range keys[:n] {
+			chunk[i] = key
+		}
+		out = append(out, chunk)
+		keys = keys[n:]
+	}
+	return out
+}
+
+// placeholders returns n comma separated `?`
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
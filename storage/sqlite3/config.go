@@ -1,28 +1,44 @@
 package sqlite3
 
-import "time"
+import (
+	"time"
+
+	"github.com/20326/flexbox/storage"
+)
 
 // Config defines the config for storage.
 type Config struct {
-	// Database name
+	// Database file
 	//
-	// Optional. Default is "fiber"
-	Database string
+	// Optional. Default is "./fiber.sqlite3"
+	Database string `yaml:"database" default:"./fiber.sqlite3"`
 
 	// Table name
 	//
 	// Optional. Default is "fiber_storage"
-	Table string
+	Table string `yaml:"table" default:"fiber_storage"`
 
 	// Reset clears any existing keys in existing Table
 	//
 	// Optional. Default is false
-	Reset bool
+	Reset bool `yaml:"reset"`
 
 	// Time before deleting expired keys
 	//
 	// Optional. Default is 10 * time.Second
-	GCInterval time.Duration
+	GCInterval time.Duration `yaml:"gcInterval" default:"10s"`
+
+	// ConnectRetries is the number of times the initial ping is retried
+	// by NewWithContext before giving up
+	//
+	// Optional. Default is 0
+	ConnectRetries int `yaml:"connectRetries"`
+
+	// ConnectBackoff is the wait before the first retry, it doubles after
+	// every attempt
+	//
+	// Optional. Default is 100 * time.Millisecond
+	ConnectBackoff time.Duration `yaml:"connectBackoff" default:"100ms"`
 
 	// //////////////////////////////////
 	// Adaptor related config options //
@@ -31,32 +47,26 @@ type Config struct {
 	// MaxIdleConns sets the maximum number of connections in the idle connection pool.
 	//
 	// Optional. Default is 100.
-	MaxIdleConns int
+	MaxIdleConns int `yaml:"maxIdleConns" default:"100"`
 
 	// MaxOpenConns sets the maximum number of open connections to the database.
 	//
 	// Optional. Default is 100.
-	MaxOpenConns int
+	MaxOpenConns int `yaml:"maxOpenConns" default:"100"`
 
 	// ConnMaxLifetime sets the maximum amount of time a connection may be reused.
 	//
 	// Optional. Default is 1 second.
-	ConnMaxLifetime time.Duration
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime" default:"1s"`
 }
 
-// ConfigDefault is the default config
-var ConfigDefault = Config{
-	// General config options
-	Database:   "./fiber.sqlite3",
-	Table:      "fiber_storage",
-	Reset:      false,
-	GCInterval: 10 * time.Second,
-
-	// Adaptor related config options
-	MaxOpenConns:    100,
-	MaxIdleConns:    100,
-	ConnMaxLifetime: 1 * time.Second,
-}
+// ConfigDefault is the default config, built from the default tags of
+// Config
+var ConfigDefault = func() Config {
+	var cfg Config
+	storage.Defaults(&cfg)
+	return cfg
+}()
 
 // Helper function to set default values
 func configDefault(config ...Config) Config {
@@ -78,6 +88,9 @@ func configDefault(config ...Config) Config {
 	if int(cfg.GCInterval.Seconds()) <= 0 {
 		cfg.GCInterval = ConfigDefault.GCInterval
 	}
+	if cfg.ConnectBackoff <= 0 {
+		cfg.ConnectBackoff = ConfigDefault.ConnectBackoff
+	}
 	if cfg.MaxIdleConns <= 0 {
 		cfg.MaxIdleConns = ConfigDefault.MaxIdleConns
 	}
@@ -0,0 +1,18 @@
+package sqlite3
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/20326/flexbox/storage"
+	"github.com/20326/flexbox/storage/storagetest"
+)
+
+func Test_SQLite3_Conformance(t *testing.T) {
+	database := filepath.Join(t.TempDir(), "conformance.sqlite3")
+	storagetest.RunConformance(t, func() storage.Storage {
+		return New(Config{
+			Database: database,
+		})
+	})
+}
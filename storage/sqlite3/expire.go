@@ -0,0 +1,38 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TTL returns the time left before key expires with second precision, ok
+// is false if key does not exist
+func (s *Storage) TTL(key string) (time.Duration, bool, error) {
+	var exp int64
+	if err := s.db.QueryRow(s.sqlTTL, key).Scan(&exp); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	now := time.Now().Unix()
+	if exp == 0 {
+		return 0, true, nil
+	}
+	if exp <= now {
+		return 0, false, nil
+	}
+	return time.Duration(exp-now) * time.Second, true, nil
+}
+
+// Touch sets the expiration of key to exp from now, 0 means no expiration
+func (s *Storage) Touch(key string, exp time.Duration) error {
+	now := time.Now()
+	var expSeconds int64
+	if exp != 0 {
+		expSeconds = now.Add(exp).Unix()
+	}
+	_, err := s.db.Exec(s.sqlTouch, expSeconds, key, now.Unix())
+	return err
+}
@@ -3,6 +3,10 @@ module storage/sqlite3
 go 1.18
 
 require (
+	github.com/20326/flexbox v0.0.0
 	github.com/gofiber/utils v1.0.1
 	github.com/mattn/go-sqlite3 v1.14.16
+	go.uber.org/goleak v1.2.1
 )
+
+replace github.com/20326/flexbox => ../..
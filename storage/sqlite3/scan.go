@@ -0,0 +1,68 @@
+package sqlite3
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// defaultPageSize is used by Scan when pageSize is not positive
+const defaultPageSize = 100
+
+// globEscaper escapes the GLOB wildcards of a prefix. sqlScan uses GLOB
+// since LIKE ignores the case of ASCII letters in SQLite
+var globEscaper = strings.NewReplacer("[", "[[]", "*", "[*]", "?", "[?]")
+
+// Scan calls fn with the unexpired keys starting with prefix in sorted
+// order, at most pageSize keys at a time. Pages are read with
+// `GLOB 'prefix*'` after the last key of the previous page, so fn may
+// modify the storage.
+func (s *Storage) Scan(ctx context.Context, prefix string, pageSize int, fn func(keys []string) error) error {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	pattern := globEscaper.Replace(prefix) + "*"
+
+	var last string
+	for {
+		keys, err := s.scanPage(ctx, pattern, last, pageSize)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		if err := fn(keys); err != nil {
+			return err
+		}
+		if len(keys) < pageSize {
+			return nil
+		}
+		last = keys[len(keys)-1]
+	}
+}
+
+func (s *Storage) scanPage(ctx context.Context, pattern, after string, limit int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.sqlScan, pattern, after, time.Now().Unix(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]string, 0, limit)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// DeletePrefix deletes every key starting with prefix in a single DELETE,
+// comparing the prefix with substr since LIKE ignores the case
+func (s *Storage) DeletePrefix(ctx context.Context, prefix string) error {
+	_, err := s.db.ExecContext(ctx, s.sqlDeletePrefix, prefix, prefix)
+	return err
+}
@@ -1,8 +1,10 @@
 package sqlite3
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -13,12 +15,26 @@ type Storage struct {
 	db         *sql.DB
 	gcInterval time.Duration
 	done       chan struct{}
+	gcDone     chan struct{}
+	cancelGC   context.CancelFunc
+	closeOnce  sync.Once
+	closeErr   error
 
 	sqlSelect string
 	sqlInsert string
 	sqlDelete string
 	sqlReset  string
 	sqlGC     string
+
+	sqlSelectMany   string
+	sqlDeleteMany   string
+	sqlScan         string
+	sqlDeletePrefix string
+	sqlTTL          string
+	sqlTouch        string
+	sqlInsertNX     string
+	sqlCAS          string
+	sqlUpdate       string
 }
 
 var (
@@ -35,15 +51,25 @@ var (
 	}
 )
 
-// New creates a new storage
+// New creates a new storage, it panics if the database cannot be opened
 func New(config ...Config) *Storage {
+	store, err := NewWithContext(context.Background(), config...)
+	if err != nil {
+		panic(err)
+	}
+	return store
+}
+
+// NewWithContext creates a new storage and returns an error instead of
+// panicking. ctx bounds opening the database, including retries.
+func NewWithContext(ctx context.Context, config ...Config) (*Storage, error) {
 	// Set default config
 	cfg := configDefault(config...)
 
 	// Create db
 	db, err := sql.Open("sqlite3", cfg.Database)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	// Set database options
@@ -52,50 +78,89 @@ func New(config ...Config) *Storage {
 	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
 	// Ping database
-	if err := db.Ping(); err != nil {
-		panic(err)
+	if err := retry(ctx, cfg, db.PingContext); err != nil {
+		_ = db.Close()
+		return nil, err
 	}
 
 	// Drop table if set to true
 	if cfg.Reset {
-		if _, err = db.Exec(fmt.Sprintf(dropQuery, cfg.Table)); err != nil {
+		if _, err = db.ExecContext(ctx, fmt.Sprintf(dropQuery, cfg.Table)); err != nil {
 			_ = db.Close()
-			panic(err)
+			return nil, err
 		}
 	}
 
 	// Init database queries
 	for _, query := range initQuery {
-		if _, err := db.Exec(fmt.Sprintf(query, cfg.Table)); err != nil {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(query, cfg.Table)); err != nil {
 			_ = db.Close()
-			panic(err)
+			return nil, err
 		}
 	}
 
 	// Create storage
 	store := &Storage{
-		db:         db,
-		gcInterval: cfg.GCInterval,
-		done:       make(chan struct{}),
-		sqlSelect:  fmt.Sprintf(`SELECT v, e FROM %s WHERE k=?;`, cfg.Table),
-		sqlInsert:  fmt.Sprintf("INSERT OR REPLACE INTO %s (k, v, e) VALUES (?,?,?)", cfg.Table),
-		sqlDelete:  fmt.Sprintf("DELETE FROM %s WHERE k=?", cfg.Table),
-		sqlReset:   fmt.Sprintf("DELETE FROM %s;", cfg.Table),
-		sqlGC:      fmt.Sprintf("DELETE FROM %s WHERE e <= ? AND e != 0", cfg.Table),
+		db:              db,
+		gcInterval:      cfg.GCInterval,
+		done:            make(chan struct{}),
+		gcDone:          make(chan struct{}),
+		sqlSelect:       fmt.Sprintf(`SELECT v, e FROM %s WHERE k=?;`, cfg.Table),
+		sqlInsert:       fmt.Sprintf("INSERT OR REPLACE INTO %s (k, v, e) VALUES (?,?,?)", cfg.Table),
+		sqlDelete:       fmt.Sprintf("DELETE FROM %s WHERE k=?", cfg.Table),
+		sqlReset:        fmt.Sprintf("DELETE FROM %s;", cfg.Table),
+		sqlGC:           fmt.Sprintf("DELETE FROM %s WHERE e <= ? AND e != 0", cfg.Table),
+		sqlSelectMany:   fmt.Sprintf("SELECT k, v, e FROM %s WHERE k IN (%%s)", cfg.Table),
+		sqlDeleteMany:   fmt.Sprintf("DELETE FROM %s WHERE k IN (%%s)", cfg.Table),
+		sqlScan:         fmt.Sprintf("SELECT k FROM %s WHERE k GLOB ? AND k > ? AND (e = 0 OR e > ?) ORDER BY k LIMIT ?", cfg.Table),
+		sqlTTL:          fmt.Sprintf("SELECT e FROM %s WHERE k=?", cfg.Table),
+		sqlDeletePrefix: fmt.Sprintf("DELETE FROM %s WHERE substr(k, 1, length(?)) = ?", cfg.Table),
+		sqlTouch:        fmt.Sprintf("UPDATE %s SET e = ? WHERE k = ? AND (e = 0 OR e > ?)", cfg.Table),
+		sqlInsertNX:     fmt.Sprintf("INSERT INTO %[1]s (k, v, e) VALUES (?,?,?) ON CONFLICT(k) DO UPDATE SET v = excluded.v, e = excluded.e WHERE %[1]s.e != 0 AND %[1]s.e <= ?", cfg.Table),
+		sqlCAS:          fmt.Sprintf("UPDATE %s SET v = ?, e = ? WHERE k = ? AND v = ? AND (e = 0 OR e > ?)", cfg.Table),
+		sqlUpdate:       fmt.Sprintf("UPDATE %s SET v = ? WHERE k = ?", cfg.Table),
 	}
 
 	// Start garbage collector
-	go store.gcTicker()
+	gcCtx, cancel := context.WithCancel(context.Background())
+	store.cancelGC = cancel
+	go store.gcTicker(gcCtx)
 
-	return store
+	return store, nil
+}
+
+// retry calls fn until it succeeds, cfg.ConnectRetries is exhausted or ctx
+// is done, doubling cfg.ConnectBackoff after every attempt.
+func retry(ctx context.Context, cfg Config, fn func(context.Context) error) error {
+	backoff := cfg.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= cfg.ConnectRetries {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
 }
 
 // Get value by key
 func (s *Storage) Get(key string) ([]byte, error) {
+	return s.GetWithContext(context.Background(), key)
+}
+
+// GetWithContext gets value by key, ctx bounds the query
+func (s *Storage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
 	if len(key) <= 0 {
 		return nil, nil
 	}
-	row := s.db.QueryRow(s.sqlSelect, key)
+	row := s.db.QueryRowContext(ctx, s.sqlSelect, key)
 	// Add db response to data
 	var (
 		data       = []byte{}
@@ -117,6 +182,11 @@ func (s *Storage) Get(key string) ([]byte, error) {
 
 // Set key with value
 func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
+	return s.SetWithContext(context.Background(), key, val, exp)
+}
+
+// SetWithContext sets key with value, ctx bounds the query
+func (s *Storage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
 	// Ain't Nobody Got Time For That
 	if len(key) <= 0 || len(val) <= 0 {
 		return nil
@@ -125,34 +195,67 @@ func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
 	if exp != 0 {
 		expSeconds = time.Now().Add(exp).Unix()
 	}
-	_, err := s.db.Exec(s.sqlInsert, key, val, expSeconds)
+	_, err := s.db.ExecContext(ctx, s.sqlInsert, key, val, expSeconds)
 	return err
 }
 
 // Delete entry by key
 func (s *Storage) Delete(key string) error {
+	return s.DeleteWithContext(context.Background(), key)
+}
+
+// DeleteWithContext deletes key by key, ctx bounds the query
+func (s *Storage) DeleteWithContext(ctx context.Context, key string) error {
 	// Ain't Nobody Got Time For That
 	if len(key) <= 0 {
 		return nil
 	}
-	_, err := s.db.Exec(s.sqlDelete, key)
+	_, err := s.db.ExecContext(ctx, s.sqlDelete, key)
 	return err
 }
 
 // Reset all entries, including unexpired
 func (s *Storage) Reset() error {
-	_, err := s.db.Exec(s.sqlReset)
+	return s.ResetWithContext(context.Background())
+}
+
+// ResetWithContext resets all keys, ctx bounds the query
+func (s *Storage) ResetWithContext(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, s.sqlReset)
 	return err
 }
 
-// Close the database
+// Close stops the gc, waits for a running gc and closes the database,
+// calling it more than once returns the first result
 func (s *Storage) Close() error {
-	s.done <- struct{}{}
-	return s.db.Close()
+	return s.Shutdown(context.Background())
+}
+
+// Shutdown is Close with a deadline, a gc still running when ctx is done is
+// canceled and ctx.Err() is returned. Calling Close or Shutdown more than
+// once returns the first result
+func (s *Storage) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		select {
+		case <-s.gcDone:
+		case <-ctx.Done():
+			s.closeErr = ctx.Err()
+			s.cancelGC()
+			<-s.gcDone
+		}
+		s.cancelGC()
+		if err := s.db.Close(); s.closeErr == nil {
+			s.closeErr = err
+		}
+	})
+	return s.closeErr
 }
 
-// gcTicker starts the gc ticker
-func (s *Storage) gcTicker() {
+// gcTicker starts the gc ticker, ctx is canceled by Shutdown to abort a
+// running gc
+func (s *Storage) gcTicker(ctx context.Context) {
+	defer close(s.gcDone)
 	ticker := time.NewTicker(s.gcInterval)
 	defer ticker.Stop()
 	for {
@@ -160,14 +263,14 @@ func (s *Storage) gcTicker() {
 		case <-s.done:
 			return
 		case t := <-ticker.C:
-			s.gc(t)
+			s.gc(ctx, t)
 		}
 	}
 }
 
 // gc deletes all expired entries
-func (s *Storage) gc(t time.Time) {
-	_, _ = s.db.Exec(s.sqlGC, t.Unix())
+func (s *Storage) gc(ctx context.Context, t time.Time) {
+	_, _ = s.db.ExecContext(ctx, s.sqlGC, t.Unix())
 }
 
 // Return database client
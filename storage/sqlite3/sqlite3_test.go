@@ -1,159 +1,117 @@
 package sqlite3
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/gofiber/utils"
 	_ "github.com/mattn/go-sqlite3"
+	"go.uber.org/goleak"
 )
 
 var testStore = New(Config{
 	Reset: true,
 })
 
-func Test_SQLite3_Set(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
-
-	err := testStore.Set(key, val, 0)
-	utils.AssertEqual(t, nil, err)
+// TestMain fails the tests if a storage leaks a goroutine
+func TestMain(m *testing.M) {
+	code := m.Run()
+	_ = testStore.Close()
+	if code == 0 {
+		if err := goleak.Find(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	}
+	os.Exit(code)
 }
 
-func Test_SQLite3_Set_Override(t *testing.T) {
+func Test_SQLite3_GC(t *testing.T) {
 	var (
-		key = "john"
-		val = []byte("doe")
+		testVal = []byte("doe")
 	)
 
-	err := testStore.Set(key, val, 0)
-	utils.AssertEqual(t, nil, err)
-
-	err = testStore.Set(key, val, 0)
+	// This key should expire
+	err := testStore.Set("john", testVal, time.Nanosecond)
 	utils.AssertEqual(t, nil, err)
-}
 
-func Test_SQLite3_Get(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-	)
-
-	err := testStore.Set(key, val, 0)
-	utils.AssertEqual(t, nil, err)
+	testStore.gc(context.Background(), time.Now())
+	row := testStore.db.QueryRow(testStore.sqlSelect, "john")
+	err = row.Scan(nil, nil)
+	utils.AssertEqual(t, sql.ErrNoRows, err)
 
-	result, err := testStore.Get(key)
+	// This key should not expire
+	err = testStore.Set("john", testVal, 0)
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, val, result)
-}
-
-func Test_SQLite3_Set_Expiration(t *testing.T) {
-	var (
-		key = "john"
-		val = []byte("doe")
-		exp = 1 * time.Second
-	)
 
-	err := testStore.Set(key, val, exp)
+	testStore.gc(context.Background(), time.Now())
+	val, err := testStore.Get("john")
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, testVal, val)
 
-	time.Sleep(1100 * time.Millisecond)
 }
 
-func Test_SQLite3_Get_Expired(t *testing.T) {
-	var (
-		key = "john"
-	)
+func Test_SQLite3_Non_UTF8(t *testing.T) {
+	val := []byte("0xF5")
 
-	result, err := testStore.Get(key)
+	err := testStore.Set("0xF6", val, 0)
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-}
-
-func Test_SQLite3_Get_NotExist(t *testing.T) {
 
-	result, err := testStore.Get("notexist")
+	result, err := testStore.Get("0xF6")
 	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
+	utils.AssertEqual(t, val, result)
 }
 
-func Test_SQLite3_Delete(t *testing.T) {
+func Test_SQLite3_WithContext(t *testing.T) {
 	var (
 		key = "john"
 		val = []byte("doe")
+		ctx = context.Background()
 	)
 
-	err := testStore.Set(key, val, 0)
-	utils.AssertEqual(t, nil, err)
-
-	err = testStore.Delete(key)
-	utils.AssertEqual(t, nil, err)
-
-	result, err := testStore.Get(key)
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-}
-
-func Test_SQLite3_Reset(t *testing.T) {
-	var (
-		val = []byte("doe")
-	)
-
-	err := testStore.Set("john1", val, 0)
-	utils.AssertEqual(t, nil, err)
-
-	err = testStore.Set("john2", val, 0)
+	err := testStore.SetWithContext(ctx, key, val, 0)
 	utils.AssertEqual(t, nil, err)
 
-	err = testStore.Reset()
+	result, err := testStore.GetWithContext(ctx, key)
 	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, val, result)
 
-	result, err := testStore.Get("john1")
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-
-	result, err = testStore.Get("john2")
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, true, len(result) == 0)
-}
-
-func Test_SQLite3_GC(t *testing.T) {
-	var (
-		testVal = []byte("doe")
-	)
-
-	// This key should expire
-	err := testStore.Set("john", testVal, time.Nanosecond)
+	err = testStore.DeleteWithContext(ctx, key)
 	utils.AssertEqual(t, nil, err)
 
-	testStore.gc(time.Now())
-	row := testStore.db.QueryRow(testStore.sqlSelect, "john")
-	err = row.Scan(nil, nil)
-	utils.AssertEqual(t, sql.ErrNoRows, err)
-
-	// This key should not expire
-	err = testStore.Set("john", testVal, 0)
-	utils.AssertEqual(t, nil, err)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
 
-	testStore.gc(time.Now())
-	val, err := testStore.Get("john")
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, testVal, val)
+	_, err = testStore.GetWithContext(canceled, key)
+	utils.AssertEqual(t, context.Canceled, err)
 
+	err = testStore.ResetWithContext(canceled)
+	utils.AssertEqual(t, context.Canceled, err)
 }
 
-func Test_SQLite3_Non_UTF8(t *testing.T) {
-	val := []byte("0xF5")
-
-	err := testStore.Set("0xF6", val, 0)
-	utils.AssertEqual(t, nil, err)
-
-	result, err := testStore.Get("0xF6")
-	utils.AssertEqual(t, nil, err)
-	utils.AssertEqual(t, val, result)
+func Test_SQLite3_Shutdown(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	for i := 0; i < 10; i++ {
+		store := New(Config{
+			Database: filepath.Join(t.TempDir(), "shutdown.sqlite3"),
+		})
+		utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+		utils.AssertEqual(t, nil, store.Shutdown(context.Background()))
+		utils.AssertEqual(t, nil, store.Close())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store := New(Config{Database: filepath.Join(t.TempDir(), "shutdown.sqlite3")})
+	err := store.Shutdown(ctx)
+	utils.AssertEqual(t, true, err == nil || err == context.Canceled)
+	utils.AssertEqual(t, err, store.Close())
 }
 
 func Test_SQLite3_Close(t *testing.T) {
@@ -162,4 +120,29 @@ func Test_SQLite3_Close(t *testing.T) {
 
 func Test_SQLite3_Conn(t *testing.T) {
 	utils.AssertEqual(t, true, testStore.Conn() != nil)
-}
\ No newline at end of file
+}
+func Test_SQLite3_NewWithContext(t *testing.T) {
+	store, err := NewWithContext(context.Background(), Config{
+		Database: "./new_with_context.sqlite3",
+		Reset:    true,
+	})
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, store.Conn() != nil)
+	utils.AssertEqual(t, nil, store.Close())
+	_ = os.Remove("./new_with_context.sqlite3")
+
+	_, err = NewWithContext(context.Background(), Config{
+		Database:       "./notexist/fiber.sqlite3",
+		ConnectRetries: 2,
+		ConnectBackoff: time.Millisecond,
+	})
+	utils.AssertEqual(t, true, err != nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewWithContext(ctx, Config{
+		Database:       "./notexist/fiber.sqlite3",
+		ConnectRetries: 2,
+	})
+	utils.AssertEqual(t, context.Canceled, err)
+}
@@ -1,6 +1,10 @@
 package storage
 
-import "time"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 var (
 	Version = "0.1.0"
@@ -8,6 +12,10 @@ var (
 	storageVersion = "github.com/gofiber/storage v1.3.6"
 )
 
+// ErrUnsupported is returned by wrappers when the wrapped storage does not
+// implement an optional interface.
+var ErrUnsupported = errors.New("storage: operation not supported by the storage")
+
 func GetVersion() string {
 	return Version
 }
@@ -35,3 +43,27 @@ type Storage interface {
 	// collectors and open connections.
 	Close() error
 }
+
+// StorageWithContext is implemented by storages whose operations accept a
+// context.Context, so deadlines and cancellations reach the backend.
+type StorageWithContext interface {
+	// GetWithContext gets the value for the given key.
+	// `nil, nil` is returned when the key does not exist
+	GetWithContext(ctx context.Context, key string) ([]byte, error)
+
+	// SetWithContext stores the given value for the given key along
+	// with an expiration value, 0 means no expiration.
+	// Empty key or value will be ignored without an error.
+	SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error
+
+	// DeleteWithContext deletes the value for the given key.
+	// It returns no error if the storage does not contain the key,
+	DeleteWithContext(ctx context.Context, key string) error
+
+	// ResetWithContext resets the storage and delete all keys.
+	ResetWithContext(ctx context.Context) error
+
+	// Close closes the storage and will stop any running garbage
+	// collectors and open connections.
+	Close() error
+}
@@ -0,0 +1,487 @@
+// Package storagetest checks that a storage.Storage implementation behaves
+// like the drivers in this repository.
+//
+//	func Test_Conformance(t *testing.T) {
+//		storagetest.RunConformance(t, func() storage.Storage {
+//			return New()
+//		})
+//	}
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/20326/flexbox/storage"
+)
+
+// LargeValueSize is the size of the value in the large value test. It fits
+// the 64 KiB BLOB column of the MySQL driver.
+const LargeValueSize = 60 << 10
+
+// expireTimeout bounds the wait for keys set with a 1 or 2 second
+// expiration, drivers may store the expiration with second precision and
+// remove expired keys in the background
+const expireTimeout = 5 * time.Second
+
+// RunConformance runs the conformance tests against the storages returned
+// by newStore, one fresh storage per test. The storage is reset before and
+// closed after every test, so it must not hold data the test may not lose.
+//
+// The optional interfaces of the storage package, StorageWithContext,
+// Batcher, Scanner, PrefixDeleter, Expirer and Atomic, are tested if the
+// storage implements them.
+func RunConformance(t *testing.T, newStore func() storage.Storage) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"SetGet", testSetGet},
+		{"GetNotExist", testGetNotExist},
+		{"EmptyKeyAndValue", testEmptyKeyAndValue},
+		{"Override", testOverride},
+		{"Delete", testDelete},
+		{"Expiration", testExpiration},
+		{"Reset", testReset},
+		{"BinaryValue", testBinaryValue},
+		{"LargeValue", testLargeValue},
+		{"Concurrent", testConcurrent},
+		{"WithContext", testWithContext},
+		{"Batcher", testBatcher},
+		{"Scanner", testScanner},
+		{"PrefixDeleter", testPrefixDeleter},
+		{"Expirer", testExpirer},
+		{"Atomic", testAtomic},
+		{"AtomicAfterReset", testAtomicAfterReset},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore()
+			defer s.Close()
+			mustNil(t, s.Reset())
+			tt.fn(t, s)
+		})
+	}
+
+	t.Run("CloseIdempotent", func(t *testing.T) {
+		s := newStore()
+		mustNil(t, s.Close())
+
+		done := make(chan error, 1)
+		go func() {
+			done <- s.Close()
+		}()
+		select {
+		case err := <-done:
+			mustNil(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the second Close to return, but it blocked")
+		}
+	})
+}
+
+func testSetGet(t *testing.T, s storage.Storage) {
+	mustNil(t, s.Set("john", []byte("doe"), 0))
+	expectValue(t, s, "john", []byte("doe"))
+}
+
+func testGetNotExist(t *testing.T, s storage.Storage) {
+	expectValue(t, s, "notexist", nil)
+	mustNil(t, s.Delete("notexist"))
+}
+
+func testEmptyKeyAndValue(t *testing.T, s storage.Storage) {
+	mustNil(t, s.Set("", []byte("doe"), 0))
+	expectValue(t, s, "", nil)
+
+	mustNil(t, s.Set("john", nil, 0))
+	expectValue(t, s, "john", nil)
+
+	mustNil(t, s.Set("john", []byte{}, 0))
+	expectValue(t, s, "john", nil)
+
+	mustNil(t, s.Delete(""))
+}
+
+func testOverride(t *testing.T, s storage.Storage) {
+	mustNil(t, s.Set("john", []byte("doe"), 0))
+	mustNil(t, s.Set("john", []byte("roe"), 0))
+	expectValue(t, s, "john", []byte("roe"))
+
+	// overriding without expiration removes the old one, joe expires
+	// when jane would have
+	mustNil(t, s.Set("jane", []byte("doe"), time.Second))
+	mustNil(t, s.Set("joe", []byte("doe"), time.Second))
+	mustNil(t, s.Set("jane", []byte("roe"), 0))
+	waitExpired(t, s, "joe")
+	expectValue(t, s, "jane", []byte("roe"))
+}
+
+func testDelete(t *testing.T, s storage.Storage) {
+	mustNil(t, s.Set("john", []byte("doe"), 0))
+	mustNil(t, s.Delete("john"))
+	expectValue(t, s, "john", nil)
+	mustNil(t, s.Delete("john"))
+}
+
+// testExpiration uses whole seconds, drivers may store the expiration with
+// second precision
+func testExpiration(t *testing.T, s storage.Storage) {
+	mustNil(t, s.Set("john", []byte("doe"), 2*time.Second))
+	mustNil(t, s.Set("jane", []byte("doe"), time.Hour))
+	expectValue(t, s, "john", []byte("doe"))
+
+	waitExpired(t, s, "john")
+	expectValue(t, s, "jane", []byte("doe"))
+}
+
+func testReset(t *testing.T, s storage.Storage) {
+	mustNil(t, s.Set("john", []byte("doe"), 0))
+	mustNil(t, s.Set("jane", []byte("doe"), time.Hour))
+	mustNil(t, s.Reset())
+	expectValue(t, s, "john", nil)
+	expectValue(t, s, "jane", nil)
+
+	mustNil(t, s.Set("john", []byte("doe"), 0))
+	expectValue(t, s, "john", []byte("doe"))
+}
+
+func testBinaryValue(t *testing.T, s storage.Storage) {
+	val := []byte{0x00, 0xff, 0xfe, '\n', 0x80, 0x00}
+	mustNil(t, s.Set("binary", val, 0))
+	expectValue(t, s, "binary", val)
+}
+
+func testLargeValue(t *testing.T, s storage.Storage) {
+	val := make([]byte, LargeValueSize)
+	for i := range val {
+		val[i] = byte(i)
+	}
+	mustNil(t, s.Set("large", val, 0))
+	expectValue(t, s, "large", val)
+}
+
+func testConcurrent(t *testing.T, s storage.Storage) {
+	const workers, ops = 8, 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*ops*3)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < ops; i++ {
+				key := fmt.Sprintf("concurrent-%d", i%10)
+				val := []byte(fmt.Sprintf("%d-%d", w, i))
+				if err := s.Set(key, val, 0); err != nil {
+					errs <- err
+				}
+				if _, err := s.Get(key); err != nil {
+					errs <- err
+				}
+				if i%5 == 0 {
+					if err := s.Delete(key); err != nil {
+						errs <- err
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Expected %v, but got %v", nil, err)
+	}
+}
+
+func testWithContext(t *testing.T, s storage.Storage) {
+	sc, ok := s.(storage.StorageWithContext)
+	if !ok {
+		t.Skip("storage does not implement storage.StorageWithContext")
+	}
+	ctx := context.Background()
+
+	mustNil(t, sc.SetWithContext(ctx, "john", []byte("doe"), 0))
+	val, err := sc.GetWithContext(ctx, "john")
+	mustNil(t, err)
+	expectEqual(t, []byte("doe"), val)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := sc.GetWithContext(canceled, "john"); err == nil {
+		t.Errorf("Expected an error for a canceled context, but got %v", err)
+	}
+
+	mustNil(t, sc.DeleteWithContext(ctx, "john"))
+	expectValue(t, s, "john", nil)
+
+	mustNil(t, sc.SetWithContext(ctx, "john", []byte("doe"), 0))
+	mustNil(t, sc.ResetWithContext(ctx))
+	expectValue(t, s, "john", nil)
+}
+
+func testBatcher(t *testing.T, s storage.Storage) {
+	b, ok := s.(storage.Batcher)
+	if !ok {
+		t.Skip("storage does not implement storage.Batcher")
+	}
+
+	mustNil(t, b.SetMany(map[string]storage.Entry{
+		"john": {Value: []byte("doe")},
+		"jane": {Value: []byte("roe"), Exp: time.Hour},
+		"":     {Value: []byte("empty")},
+		"joe":  {},
+	}))
+	values, err := b.GetMany([]string{"john", "jane", "joe", "notexist"})
+	mustNil(t, err)
+	expectEqual(t, map[string][]byte{"john": []byte("doe"), "jane": []byte("roe")}, values)
+
+	values, err = b.GetMany(nil)
+	mustNil(t, err)
+	expectEqual(t, 0, len(values))
+
+	mustNil(t, b.DeleteMany([]string{"john", "jane", "notexist"}))
+	expectValue(t, s, "john", nil)
+	expectValue(t, s, "jane", nil)
+	mustNil(t, b.DeleteMany(nil))
+}
+
+func testScanner(t *testing.T, s storage.Storage) {
+	sc, ok := s.(storage.Scanner)
+	if !ok {
+		t.Skip("storage does not implement storage.Scanner")
+	}
+
+	// prefixes are case-sensitive like keys, LIKE wildcards are literal
+	for _, key := range []string{"tenant-a:1", "tenant-a:2", "tenant-a:3", "Tenant-a:4", "TENANT-A:5", "tenant-b:1", "tenant_a:1", "tenant%a:1"} {
+		mustNil(t, s.Set(key, []byte("1"), 0))
+	}
+	mustNil(t, s.Set("tenant-a:expired", []byte("1"), time.Second))
+	waitExpired(t, s, "tenant-a:expired")
+
+	seen := map[string]bool{}
+	err := sc.Scan(context.Background(), "tenant-a:", 2, func(keys []string) error {
+		for _, key := range keys {
+			seen[key] = true
+		}
+		return nil
+	})
+	mustNil(t, err)
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	expectEqual(t, []string{"tenant-a:1", "tenant-a:2", "tenant-a:3"}, keys)
+
+	// fn may modify the storage
+	err = sc.Scan(context.Background(), "tenant-a:", 2, func(keys []string) error {
+		for _, key := range keys {
+			if err := s.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	mustNil(t, err)
+	expectValue(t, s, "tenant-a:3", nil)
+	expectValue(t, s, "Tenant-a:4", []byte("1"))
+
+	stop := fmt.Errorf("stop")
+	err = sc.Scan(context.Background(), "", 1, func(keys []string) error {
+		return stop
+	})
+	expectEqual(t, stop, err)
+}
+
+func testPrefixDeleter(t *testing.T, s storage.Storage) {
+	d, ok := s.(storage.PrefixDeleter)
+	if !ok {
+		t.Skip("storage does not implement storage.PrefixDeleter")
+	}
+
+	for _, key := range []string{"tenant-a:1", "tenant-a:2", "Tenant-a:3", "tenant-b:1", "tenant_a:1", "tenant%a:1"} {
+		mustNil(t, s.Set(key, []byte("1"), 0))
+	}
+	mustNil(t, d.DeletePrefix(context.Background(), "tenant-a:"))
+
+	expectValue(t, s, "tenant-a:1", nil)
+	expectValue(t, s, "tenant-a:2", nil)
+	// prefixes are case-sensitive like keys
+	for _, key := range []string{"Tenant-a:3", "tenant-b:1", "tenant_a:1", "tenant%a:1"} {
+		expectValue(t, s, key, []byte("1"))
+	}
+}
+
+func testExpirer(t *testing.T, s storage.Storage) {
+	e, ok := s.(storage.Expirer)
+	if !ok {
+		t.Skip("storage does not implement storage.Expirer")
+	}
+
+	_, ok, err := e.TTL("john")
+	mustNil(t, err)
+	expectEqual(t, false, ok)
+
+	mustNil(t, s.Set("john", []byte("doe"), 0))
+	ttl, ok, err := e.TTL("john")
+	mustNil(t, err)
+	expectEqual(t, true, ok)
+	expectEqual(t, time.Duration(0), ttl)
+
+	mustNil(t, e.Touch("john", time.Hour))
+	ttl, ok, err = e.TTL("john")
+	mustNil(t, err)
+	expectEqual(t, true, ok)
+	expectEqual(t, true, ttl > 59*time.Minute && ttl <= time.Hour)
+
+	// a zero expiration removes it
+	mustNil(t, e.Touch("john", 0))
+	ttl, ok, err = e.TTL("john")
+	mustNil(t, err)
+	expectEqual(t, true, ok)
+	expectEqual(t, time.Duration(0), ttl)
+
+	mustNil(t, e.Touch("john", time.Second))
+	waitExpired(t, s, "john")
+
+	mustNil(t, e.Touch("notexist", time.Hour))
+	expectValue(t, s, "notexist", nil)
+}
+
+// testAtomicAfterReset checks that Reset keeps what SetIfNotExists relies
+// on, like a unique index
+func testAtomicAfterReset(t *testing.T, s storage.Storage) {
+	a, ok := s.(storage.Atomic)
+	if !ok {
+		t.Skip("storage does not implement storage.Atomic")
+	}
+
+	mustNil(t, s.Set("lock", []byte("a"), 0))
+	mustNil(t, s.Reset())
+
+	ok, err := a.SetIfNotExists("lock", []byte("a"), 0)
+	mustNil(t, err)
+	expectEqual(t, true, ok)
+	ok, err = a.SetIfNotExists("lock", []byte("b"), 0)
+	mustNil(t, err)
+	expectEqual(t, false, ok)
+	expectValue(t, s, "lock", []byte("a"))
+}
+
+func testAtomic(t *testing.T, s storage.Storage) {
+	a, ok := s.(storage.Atomic)
+	if !ok {
+		t.Skip("storage does not implement storage.Atomic")
+	}
+
+	ok, err := a.SetIfNotExists("lock", []byte("a"), 2*time.Second)
+	mustNil(t, err)
+	expectEqual(t, true, ok)
+	ok, err = a.SetIfNotExists("lock", []byte("b"), 0)
+	mustNil(t, err)
+	expectEqual(t, false, ok)
+
+	// expired keys do not exist
+	eventually(t, "lock to expire", func() bool {
+		ok, err = a.SetIfNotExists("lock", []byte("b"), 0)
+		mustNil(t, err)
+		return ok
+	})
+
+	ok, err = a.CompareAndSwap("lock", []byte("a"), []byte("c"), 0)
+	mustNil(t, err)
+	expectEqual(t, false, ok)
+	ok, err = a.CompareAndSwap("lock", []byte("b"), []byte("c"), 0)
+	mustNil(t, err)
+	expectEqual(t, true, ok)
+	expectValue(t, s, "lock", []byte("c"))
+	// a swap with the same value still matched
+	ok, err = a.CompareAndSwap("lock", []byte("c"), []byte("c"), 0)
+	mustNil(t, err)
+	expectEqual(t, true, ok)
+
+	ok, err = a.CompareAndSwap("new", nil, []byte("a"), 0)
+	mustNil(t, err)
+	expectEqual(t, true, ok)
+
+	const workers, incs = 4, 25
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < incs; i++ {
+				if _, err := a.Increment("counter", 1, time.Hour); err != nil {
+					t.Errorf("Expected %v, but got %v", nil, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	n, err := a.Increment("counter", -1, 0)
+	mustNil(t, err)
+	expectEqual(t, int64(workers*incs-1), n)
+	expectValue(t, s, "counter", []byte(fmt.Sprint(workers*incs-1)))
+
+	if _, err := a.Increment("lock", 1, 0); err == nil {
+		t.Errorf("Expected an error for a value that is not an integer, but got %v", err)
+	}
+}
+
+// waitExpired waits for key to expire, see eventually
+func waitExpired(t *testing.T, s storage.Storage, key string) {
+	t.Helper()
+	eventually(t, fmt.Sprintf("%q to expire", key), func() bool {
+		val, err := s.Get(key)
+		mustNil(t, err)
+		return val == nil
+	})
+}
+
+// eventually polls cond until it is true, for at most expireTimeout
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(expireTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s within %v", what, expireTimeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func expectValue(t *testing.T, s storage.Storage, key string, expected []byte) {
+	t.Helper()
+	val, err := s.Get(key)
+	mustNil(t, err)
+	if !bytes.Equal(val, expected) || (expected == nil) != (val == nil) {
+		t.Errorf("Expected %q for %q, but got %q", expected, key, val)
+	}
+}
+
+func expectEqual(t *testing.T, expected, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, but got %v", expected, actual)
+	}
+}
+
+func mustNil(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("Expected %v, but got %v", nil, err)
+	}
+}
@@ -0,0 +1,68 @@
+package storagetest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/20326/flexbox/storage"
+)
+
+// mapStorage is the smallest storage that passes the conformance tests
+type mapStorage struct {
+	mux sync.Mutex
+	db  map[string]mapEntry
+}
+
+type mapEntry struct {
+	val    []byte
+	expiry time.Time
+}
+
+func (s *mapStorage) Get(key string) ([]byte, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	e, ok := s.db[key]
+	if !ok || !e.expiry.IsZero() && !time.Now().Before(e.expiry) {
+		return nil, nil
+	}
+	return e.val, nil
+}
+
+func (s *mapStorage) Set(key string, val []byte, exp time.Duration) error {
+	if len(key) <= 0 || len(val) <= 0 {
+		return nil
+	}
+	e := mapEntry{val: val}
+	if exp != 0 {
+		e.expiry = time.Now().Add(exp)
+	}
+	s.mux.Lock()
+	s.db[key] = e
+	s.mux.Unlock()
+	return nil
+}
+
+func (s *mapStorage) Delete(key string) error {
+	s.mux.Lock()
+	delete(s.db, key)
+	s.mux.Unlock()
+	return nil
+}
+
+func (s *mapStorage) Reset() error {
+	s.mux.Lock()
+	s.db = map[string]mapEntry{}
+	s.mux.Unlock()
+	return nil
+}
+
+func (s *mapStorage) Close() error {
+	return nil
+}
+
+func TestRunConformance(t *testing.T) {
+	RunConformance(t, func() storage.Storage {
+		return &mapStorage{db: map[string]mapEntry{}}
+	})
+}
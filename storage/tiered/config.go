@@ -0,0 +1,87 @@
+package tiered
+
+import (
+	"time"
+
+	"github.com/20326/flexbox/storage"
+)
+
+// Write modes for Config.Mode
+const (
+	// WriteThrough writes to L2 before the call returns
+	WriteThrough = "write-through"
+	// WriteBehind writes to L1 and queues the write to L2
+	WriteBehind = "write-behind"
+)
+
+// Config defines the config for storage.
+type Config struct {
+	// L1 is the local cache in front of L2, it is closed with the storage
+	//
+	// Optional. Default is a new memory storage
+	L1 storage.Storage `yaml:"-"`
+
+	// Maximum time a value stays in L1, values are set in L1 with the
+	// shorter of L1TTL and their own expiration. It bounds how long an
+	// instance may read a value changed by another instance when there is
+	// no Invalidator
+	//
+	// Default is 1 minute
+	L1TTL time.Duration `yaml:"l1TTL" default:"1m"`
+
+	// Mode of the writes: "write-through" or "write-behind". In
+	// write-behind mode writes to L2 are applied in order by a background
+	// goroutine, Flush waits for them and Close writes the queued ones
+	//
+	// Default is "write-through"
+	Mode string `yaml:"mode" default:"write-through"`
+
+	// Size of the write-behind queue, writes block when it is full
+	//
+	// Default is 1024
+	QueueSize int `yaml:"queueSize" default:"1024"`
+
+	// Invalidator tells the other instances which keys changed, so they
+	// drop them from their L1, see NewRedisInvalidator. It is closed with
+	// the storage
+	//
+	// Optional. Default is nil, L1 entries expire after L1TTL
+	Invalidator Invalidator `yaml:"-"`
+
+	// OnError is called with the errors of the background writes to L2
+	// and of the invalidations, key is empty for a reset
+	//
+	// Optional. Default is nil
+	OnError func(key string, err error) `yaml:"-"`
+}
+
+// ConfigDefault is the default config, built from the default tags of
+// Config
+var ConfigDefault = func() Config {
+	var cfg Config
+	storage.Defaults(&cfg)
+	return cfg
+}()
+
+// configDefault is a helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.L1TTL <= 0 {
+		cfg.L1TTL = ConfigDefault.L1TTL
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = ConfigDefault.Mode
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = ConfigDefault.QueueSize
+	}
+	return cfg
+}
@@ -0,0 +1,30 @@
+package tiered
+
+import (
+	"testing"
+
+	"github.com/20326/flexbox/storage"
+	"github.com/20326/flexbox/storage/storagetest"
+
+	"storage/memory"
+)
+
+func Test_Tiered_Conformance(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Storage {
+		store, err := New(memory.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func Test_Tiered_Conformance_WriteBehind(t *testing.T) {
+	storagetest.RunConformance(t, func() storage.Storage {
+		store, err := New(memory.New(), Config{Mode: WriteBehind})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
@@ -0,0 +1,20 @@
+module storage/tiered
+
+go 1.18
+
+require (
+	github.com/20326/flexbox v0.0.0
+	github.com/gofiber/utils v1.0.1
+	github.com/redis/go-redis/v9 v9.0.3
+	storage/memory v0.0.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+)
+
+replace (
+	github.com/20326/flexbox => ../..
+	storage/memory => ../memory
+)
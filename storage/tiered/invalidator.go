@@ -0,0 +1,114 @@
+package tiered
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultChannel is used by NewRedisInvalidator when channel is empty
+const defaultChannel = "tiered:invalidate"
+
+// Invalidator tells the instances sharing an L2 which keys changed
+type Invalidator interface {
+	// Publish tells the other instances that key changed, an empty key
+	// means every key
+	Publish(ctx context.Context, key string) error
+
+	// Subscribe calls fn with the keys published by the other instances
+	// until Close, an empty key means every key
+	Subscribe(fn func(key string)) error
+
+	// Close stops the subscription
+	Close() error
+}
+
+// RedisInvalidator is an Invalidator over Redis pub/sub. Messages are the
+// ID of the publisher and the key, so an instance skips its own messages.
+// Messages published while the connection is down are lost, every key is
+// invalidated when it comes back.
+type RedisInvalidator struct {
+	db      redis.UniversalClient
+	channel string
+	id      string
+
+	mux    sync.Mutex
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+// NewRedisInvalidator returns an Invalidator publishing on channel, the
+// client of a redis storage is returned by its Conn method
+func NewRedisInvalidator(db redis.UniversalClient, channel string) *RedisInvalidator {
+	if channel == "" {
+		channel = defaultChannel
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return &RedisInvalidator{
+		db:      db,
+		channel: channel,
+		id:      hex.EncodeToString(id),
+	}
+}
+
+// Publish sends key to the other instances
+func (r *RedisInvalidator) Publish(ctx context.Context, key string) error {
+	return r.db.Publish(ctx, r.channel, r.id+":"+key).Err()
+}
+
+// Subscribe subscribes to the channel and calls fn from a goroutine
+func (r *RedisInvalidator) Subscribe(fn func(key string)) error {
+	ctx := context.Background()
+	pubsub := r.db.Subscribe(ctx, r.channel)
+	// Wait for the confirmation, so no message published after Subscribe
+	// returns is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+
+	r.mux.Lock()
+	r.pubsub = pubsub
+	r.done = make(chan struct{})
+	r.mux.Unlock()
+
+	go func() {
+		defer close(r.done)
+		for msg := range pubsub.ChannelWithSubscriptions() {
+			switch msg := msg.(type) {
+			case *redis.Subscription:
+				// Resubscribed after a reconnect
+				if msg.Kind == "subscribe" {
+					fn("")
+				}
+			case *redis.Message:
+				id, key, ok := strings.Cut(msg.Payload, ":")
+				if ok && id != r.id {
+					fn(key)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Close unsubscribes and waits for the last call of fn
+func (r *RedisInvalidator) Close() error {
+	r.mux.Lock()
+	pubsub, done := r.pubsub, r.done
+	r.pubsub = nil
+	r.mux.Unlock()
+	if pubsub == nil {
+		return nil
+	}
+	err := pubsub.Close()
+	<-done
+	return err
+}
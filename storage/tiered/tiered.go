@@ -0,0 +1,514 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/20326/flexbox/storage"
+	"storage/memory"
+)
+
+// errClosed is returned by the writes of a write-behind storage after Close
+var errClosed = errors.New("tiered: storage is closed")
+
+// Storage is a read-through cache of an L2 storage in an L1 storage
+type Storage struct {
+	l1      storage.Storage
+	l2      storage.Storage
+	l1c     storage.StorageWithContext
+	l2c     storage.StorageWithContext
+	ttl     time.Duration
+	inv     Invalidator
+	onError func(key string, err error)
+
+	// mux guards the loads of missed keys and the pending writes
+	mux     sync.Mutex
+	calls   map[string]*call
+	pending map[string]*op
+
+	// locks serialize the write-through writes of a key to L2 and L1
+	locks [64]sync.Mutex
+
+	// writeMux serializes the write-behind writes, so the queue is in the
+	// order of the writes to L1
+	writeMux   sync.Mutex
+	closed     bool
+	queue      chan *op
+	abort      chan struct{}
+	writerDone chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// call is a load of a missed key from L2, concurrent misses of the key
+// wait for it. A write of the key during the load marks it stale, so the
+// loaded value is not put in L1.
+type call struct {
+	done  chan struct{}
+	val   []byte
+	err   error
+	stale bool
+}
+
+// op is a queued write-behind write, a nil val deletes the key. Flush
+// queues an op with flushed.
+type op struct {
+	key     string
+	val     []byte
+	expiry  time.Time
+	flushed chan struct{}
+}
+
+// expired reports whether the value of o has expired
+func (o *op) expired() bool {
+	return !o.expiry.IsZero() && !time.Now().Before(o.expiry)
+}
+
+// New layers an L1 storage over l2, it fails on an unknown Config.Mode or
+// if Config.Invalidator cannot subscribe
+func New(l2 storage.Storage, config ...Config) (*Storage, error) {
+	// Set default config
+	cfg := configDefault(config...)
+
+	if cfg.Mode != WriteThrough && cfg.Mode != WriteBehind {
+		return nil, fmt.Errorf("tiered: unknown mode %q", cfg.Mode)
+	}
+	ownL1 := cfg.L1 == nil
+	if ownL1 {
+		cfg.L1 = memory.New()
+	}
+
+	store := &Storage{
+		l1:      cfg.L1,
+		l2:      l2,
+		l1c:     storage.WithContext(cfg.L1),
+		l2c:     storage.WithContext(l2),
+		ttl:     cfg.L1TTL,
+		inv:     cfg.Invalidator,
+		onError: cfg.OnError,
+		calls:   make(map[string]*call),
+	}
+	if cfg.Mode == WriteBehind {
+		store.pending = make(map[string]*op)
+		store.queue = make(chan *op, cfg.QueueSize)
+		store.abort = make(chan struct{})
+		store.writerDone = make(chan struct{})
+	}
+	if store.inv != nil {
+		if err := store.inv.Subscribe(store.invalidate); err != nil {
+			if ownL1 {
+				_ = cfg.L1.Close()
+			}
+			return nil, err
+		}
+	}
+	if store.queue != nil {
+		go store.writeBehind()
+	}
+	return store, nil
+}
+
+// Get value by key
+func (s *Storage) Get(key string) ([]byte, error) {
+	return s.GetWithContext(context.Background(), key)
+}
+
+// Set key with value
+func (s *Storage) Set(key string, val []byte, exp time.Duration) error {
+	return s.SetWithContext(context.Background(), key, val, exp)
+}
+
+// Delete key by key
+func (s *Storage) Delete(key string) error {
+	return s.DeleteWithContext(context.Background(), key)
+}
+
+// Reset all keys
+func (s *Storage) Reset() error {
+	return s.ResetWithContext(context.Background())
+}
+
+// Close writes the queued writes, then closes the Invalidator, L1 and L2
+func (s *Storage) Close() error {
+	return s.Shutdown(context.Background())
+}
+
+// GetWithContext gets the value of key from L1, or from L2 on a miss.
+// Concurrent misses of a key share one read of L2 and its value is kept in
+// L1 for L1TTL, or until it expires if L2 implements storage.Expirer.
+func (s *Storage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	if len(key) <= 0 {
+		return nil, nil
+	}
+	val, err := s.l1c.GetWithContext(ctx, key)
+	if err != nil || len(val) > 0 {
+		return val, err
+	}
+
+	// A queued write may be newer than L2, L1 may have evicted it already
+	if s.queue != nil {
+		s.mux.Lock()
+		o, ok := s.pending[key]
+		s.mux.Unlock()
+		if ok {
+			if o.expired() {
+				return nil, nil
+			}
+			return o.val, nil
+		}
+	}
+	return s.load(ctx, key)
+}
+
+// SetWithContext sets key in L2 and L1, in write-behind mode the write to
+// L2 is queued
+func (s *Storage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	// Ain't Nobody Got Time For That
+	if len(key) <= 0 || len(val) <= 0 {
+		return nil
+	}
+	if s.queue != nil {
+		o := &op{key: key, val: val}
+		if exp > 0 {
+			o.expiry = time.Now().Add(exp)
+		}
+		return s.writeBack(ctx, o, func() error {
+			return s.l1c.SetWithContext(ctx, key, val, s.l1Exp(exp))
+		})
+	}
+
+	lock := s.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	if err := s.l2c.SetWithContext(ctx, key, val, exp); err != nil {
+		return err
+	}
+	s.forget(key)
+	if err := s.l1c.SetWithContext(ctx, key, val, s.l1Exp(exp)); err != nil {
+		return err
+	}
+	s.publish(ctx, key)
+	return nil
+}
+
+// DeleteWithContext deletes key from L2 and L1, in write-behind mode the
+// delete from L2 is queued
+func (s *Storage) DeleteWithContext(ctx context.Context, key string) error {
+	if len(key) <= 0 {
+		return nil
+	}
+	if s.queue != nil {
+		return s.writeBack(ctx, &op{key: key}, func() error {
+			return s.l1c.DeleteWithContext(ctx, key)
+		})
+	}
+
+	lock := s.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	if err := s.l2c.DeleteWithContext(ctx, key); err != nil {
+		return err
+	}
+	s.forget(key)
+	if err := s.l1c.DeleteWithContext(ctx, key); err != nil {
+		return err
+	}
+	s.publish(ctx, key)
+	return nil
+}
+
+// ResetWithContext resets L2 and L1, in write-behind mode the queued
+// writes are written first
+func (s *Storage) ResetWithContext(ctx context.Context) error {
+	if s.queue != nil {
+		s.writeMux.Lock()
+		defer s.writeMux.Unlock()
+		if err := s.flush(ctx); err != nil {
+			return err
+		}
+	} else {
+		for i := range s.locks {
+			s.locks[i].Lock()
+			defer s.locks[i].Unlock()
+		}
+	}
+
+	if err := s.l2c.ResetWithContext(ctx); err != nil {
+		return err
+	}
+	s.forgetAll()
+	if err := s.l1c.ResetWithContext(ctx); err != nil {
+		return err
+	}
+	s.publish(ctx, "")
+	return nil
+}
+
+// Flush waits until the queued writes are written to L2, it returns
+// immediately in write-through mode
+func (s *Storage) Flush(ctx context.Context) error {
+	if s.queue == nil {
+		return nil
+	}
+	s.writeMux.Lock()
+	defer s.writeMux.Unlock()
+	return s.flush(ctx)
+}
+
+// Shutdown is Close with a deadline for the queued writes. When ctx is
+// done first the writes left are dropped and ctx.Err() is returned, the
+// storages are closed either way.
+func (s *Storage) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		if s.queue != nil {
+			s.writeMux.Lock()
+			s.closed = true
+			close(s.queue)
+			s.writeMux.Unlock()
+
+			select {
+			case <-s.writerDone:
+			case <-ctx.Done():
+				s.closeErr = ctx.Err()
+				close(s.abort)
+				<-s.writerDone
+			}
+		}
+
+		// The Invalidator may use the client of L2, close it first
+		if s.inv != nil {
+			if err := s.inv.Close(); s.closeErr == nil {
+				s.closeErr = err
+			}
+		}
+		if err := s.l1.Close(); s.closeErr == nil {
+			s.closeErr = err
+		}
+		if err := s.l2.Close(); s.closeErr == nil {
+			s.closeErr = err
+		}
+	})
+	return s.closeErr
+}
+
+// L1 returns the local storage
+func (s *Storage) L1() storage.Storage {
+	return s.l1
+}
+
+// L2 returns the shared storage
+func (s *Storage) L2() storage.Storage {
+	return s.l2
+}
+
+// load reads a missed key from L2 and puts it in L1, concurrent misses of
+// the key wait for the first one
+func (s *Storage) load(ctx context.Context, key string) ([]byte, error) {
+	s.mux.Lock()
+	if c, ok := s.calls[key]; ok {
+		s.mux.Unlock()
+		select {
+		case <-c.done:
+			return c.val, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	s.calls[key] = c
+	s.mux.Unlock()
+
+	c.val, c.err = s.l2c.GetWithContext(ctx, key)
+	exp := s.ttl
+	if e, ok := s.l2.(storage.Expirer); ok && c.err == nil && len(c.val) > 0 {
+		if ttl, ok, err := e.TTL(key); err != nil || !ok {
+			c.stale = true
+		} else if ttl > 0 && ttl < exp {
+			exp = ttl
+		}
+	}
+
+	s.mux.Lock()
+	fill := c.err == nil && len(c.val) > 0 && !c.stale
+	s.mux.Unlock()
+	if fill {
+		// The value is returned anyway, a failed fill is only a miss later
+		_ = s.l1c.SetWithContext(ctx, key, c.val, exp)
+	}
+
+	// The call stays registered during the fill, so a write of the key
+	// after it overwrites the fill and a write during it marks the call
+	// stale, then the fill may be older than the write and is dropped
+	s.mux.Lock()
+	delete(s.calls, key)
+	undo := fill && c.stale
+	s.mux.Unlock()
+	if undo {
+		_ = s.l1c.DeleteWithContext(ctx, key)
+	}
+	close(c.done)
+	return c.val, c.err
+}
+
+// forget marks the load of key stale
+func (s *Storage) forget(key string) {
+	s.mux.Lock()
+	if c, ok := s.calls[key]; ok {
+		c.stale = true
+	}
+	s.mux.Unlock()
+}
+
+// forgetAll marks every load stale
+func (s *Storage) forgetAll() {
+	s.mux.Lock()
+	for _, c := range s.calls {
+		c.stale = true
+	}
+	s.mux.Unlock()
+}
+
+// invalidate drops a key published by another instance from L1, an empty
+// key drops every key
+func (s *Storage) invalidate(key string) {
+	var err error
+	if len(key) <= 0 {
+		s.forgetAll()
+		err = s.l1.Reset()
+	} else {
+		s.forget(key)
+		err = s.l1.Delete(key)
+	}
+	if err != nil {
+		s.fail(key, err)
+	}
+}
+
+// publish tells the other instances that key changed
+func (s *Storage) publish(ctx context.Context, key string) {
+	if s.inv == nil {
+		return
+	}
+	if err := s.inv.Publish(ctx, key); err != nil {
+		s.fail(key, err)
+	}
+}
+
+func (s *Storage) fail(key string, err error) {
+	if s.onError != nil {
+		s.onError(key, err)
+	}
+}
+
+// l1Exp returns the expiration of a value in L1
+func (s *Storage) l1Exp(exp time.Duration) time.Duration {
+	if exp <= 0 || exp > s.ttl {
+		return s.ttl
+	}
+	return exp
+}
+
+// lock returns the write-through lock of key
+func (s *Storage) lock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.locks[h.Sum32()%uint32(len(s.locks))]
+}
+
+// writeBack records o as pending, queues it and writes L1
+func (s *Storage) writeBack(ctx context.Context, o *op, writeL1 func() error) error {
+	s.writeMux.Lock()
+	defer s.writeMux.Unlock()
+	if s.closed {
+		return errClosed
+	}
+
+	s.mux.Lock()
+	prev := s.pending[o.key]
+	s.pending[o.key] = o
+	if c, ok := s.calls[o.key]; ok {
+		c.stale = true
+	}
+	s.mux.Unlock()
+
+	select {
+	case s.queue <- o:
+	case <-ctx.Done():
+		s.mux.Lock()
+		if s.pending[o.key] == o {
+			if prev != nil {
+				s.pending[o.key] = prev
+			} else {
+				delete(s.pending, o.key)
+			}
+		}
+		s.mux.Unlock()
+		return ctx.Err()
+	}
+	return writeL1()
+}
+
+// flush queues a flush and waits for it, the caller holds writeMux
+func (s *Storage) flush(ctx context.Context) error {
+	if s.closed {
+		return errClosed
+	}
+	o := &op{flushed: make(chan struct{})}
+	select {
+	case s.queue <- o:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-o.flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writeBehind writes the queued ops to L2 in order until the queue is
+// closed
+func (s *Storage) writeBehind() {
+	defer close(s.writerDone)
+	ctx := context.Background()
+	for o := range s.queue {
+		if o.flushed != nil {
+			close(o.flushed)
+			continue
+		}
+		select {
+		case <-s.abort:
+			// Shutdown ran out of time, drop the write
+		default:
+			var err error
+			if o.val == nil || o.expired() {
+				err = s.l2c.DeleteWithContext(ctx, o.key)
+			} else if o.expiry.IsZero() {
+				err = s.l2c.SetWithContext(ctx, o.key, o.val, 0)
+			} else {
+				err = s.l2c.SetWithContext(ctx, o.key, o.val, time.Until(o.expiry))
+			}
+			if err != nil {
+				s.fail(o.key, err)
+			} else {
+				s.publish(ctx, o.key)
+			}
+		}
+		s.done(o)
+	}
+}
+
+// done removes o from the pending writes unless a newer write replaced it
+func (s *Storage) done(o *op) {
+	s.mux.Lock()
+	if s.pending[o.key] == o {
+		delete(s.pending, o.key)
+	}
+	s.mux.Unlock()
+}
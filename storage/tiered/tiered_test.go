@@ -0,0 +1,362 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/utils"
+
+	"storage/memory"
+)
+
+// slowStorage is an L2 counting its reads, reads and writes wait for gate
+// when it is set
+type slowStorage struct {
+	*memory.Storage
+	gets int32
+	gate chan struct{}
+}
+
+func (s *slowStorage) Get(key string) ([]byte, error) {
+	return s.GetWithContext(context.Background(), key)
+}
+
+func (s *slowStorage) Set(key string, val []byte, exp time.Duration) error {
+	return s.SetWithContext(context.Background(), key, val, exp)
+}
+
+func (s *slowStorage) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	atomic.AddInt32(&s.gets, 1)
+	if s.gate != nil {
+		<-s.gate
+	}
+	return s.Storage.GetWithContext(ctx, key)
+}
+
+func (s *slowStorage) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	if s.gate != nil {
+		<-s.gate
+	}
+	return s.Storage.SetWithContext(ctx, key, val, exp)
+}
+
+// bus is an in-process Invalidator network
+type bus struct {
+	mux  sync.Mutex
+	subs []*busInvalidator
+}
+
+type busInvalidator struct {
+	bus *bus
+	fn  func(key string)
+}
+
+func (b *bus) invalidator() *busInvalidator {
+	return &busInvalidator{bus: b}
+}
+
+func (i *busInvalidator) Publish(_ context.Context, key string) error {
+	i.bus.mux.Lock()
+	defer i.bus.mux.Unlock()
+	for _, sub := range i.bus.subs {
+		if sub != i {
+			sub.fn(key)
+		}
+	}
+	return nil
+}
+
+func (i *busInvalidator) Subscribe(fn func(key string)) error {
+	i.bus.mux.Lock()
+	defer i.bus.mux.Unlock()
+	i.fn = fn
+	i.bus.subs = append(i.bus.subs, i)
+	return nil
+}
+
+func (i *busInvalidator) Close() error {
+	return nil
+}
+
+func newStore(t *testing.T, l2 *slowStorage, config ...Config) *Storage {
+	store, err := New(l2, config...)
+	utils.AssertEqual(t, nil, err)
+	return store
+}
+
+func Test_Tiered_Set_Get(t *testing.T) {
+	l2 := &slowStorage{Storage: memory.New()}
+	store := newStore(t, l2)
+	defer store.Close()
+
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+	result, err := l2.Storage.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), result)
+
+	result, err = store.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), result)
+	utils.AssertEqual(t, int32(0), atomic.LoadInt32(&l2.gets))
+
+	// A miss reads L2 once and fills L1
+	utils.AssertEqual(t, nil, l2.Storage.Set("jane", []byte("doe"), 0))
+	for i := 0; i < 3; i++ {
+		result, err = store.Get("jane")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, []byte("doe"), result)
+	}
+	utils.AssertEqual(t, int32(1), atomic.LoadInt32(&l2.gets))
+
+	utils.AssertEqual(t, nil, store.Delete("jane"))
+	result, err = store.Get("jane")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, len(result) == 0)
+	result, err = l2.Storage.Get("jane")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, len(result) == 0)
+}
+
+func Test_Tiered_L1TTL(t *testing.T) {
+	l2 := &slowStorage{Storage: memory.New()}
+	store := newStore(t, l2, Config{L1TTL: 100 * time.Millisecond})
+	defer store.Close()
+
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, l2.Storage.Set("john", []byte("smith"), 0))
+
+	// L1 serves the old value until L1TTL
+	result, err := store.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), result)
+
+	time.Sleep(150 * time.Millisecond)
+	result, err = store.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("smith"), result)
+
+	// Values expiring sooner than L1TTL keep their expiration in L1
+	utils.AssertEqual(t, nil, store.Set("jane", []byte("doe"), 50*time.Millisecond))
+	time.Sleep(75 * time.Millisecond)
+	result, err = store.Get("jane")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, len(result) == 0)
+}
+
+func Test_Tiered_Singleflight(t *testing.T) {
+	l2 := &slowStorage{Storage: memory.New()}
+	utils.AssertEqual(t, nil, l2.Storage.Set("john", []byte("doe"), 0))
+	store := newStore(t, l2)
+	defer store.Close()
+
+	l2.gate = make(chan struct{})
+	var wg sync.WaitGroup
+	results := make([][]byte, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = store.Get("john")
+		}(i)
+	}
+	// Let the misses pile up behind the first read
+	time.Sleep(50 * time.Millisecond)
+	close(l2.gate)
+	wg.Wait()
+
+	utils.AssertEqual(t, int32(1), atomic.LoadInt32(&l2.gets))
+	for _, result := range results {
+		utils.AssertEqual(t, []byte("doe"), result)
+	}
+}
+
+func Test_Tiered_Singleflight_Stale(t *testing.T) {
+	l2 := &slowStorage{Storage: memory.New(), gate: make(chan struct{})}
+	utils.AssertEqual(t, nil, l2.Storage.Set("john", []byte("doe"), 0))
+	store := newStore(t, l2, Config{Mode: WriteBehind})
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = store.Get("john")
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// The write during the read of L2 keeps the read value out of L1
+	utils.AssertEqual(t, nil, store.Set("john", []byte("smith"), 0))
+	close(l2.gate)
+	<-done
+
+	result, err := store.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("smith"), result)
+	utils.AssertEqual(t, nil, store.Close())
+}
+
+// slowL1 is an L1 whose writes wait for gate
+type slowL1 struct {
+	*memory.Storage
+	gate chan struct{}
+}
+
+func (s *slowL1) Set(key string, val []byte, exp time.Duration) error {
+	return s.SetWithContext(context.Background(), key, val, exp)
+}
+
+func (s *slowL1) SetWithContext(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	<-s.gate
+	return s.Storage.SetWithContext(ctx, key, val, exp)
+}
+
+func Test_Tiered_Fill_Stale(t *testing.T) {
+	l2 := &slowStorage{Storage: memory.New()}
+	utils.AssertEqual(t, nil, l2.Storage.Set("john", []byte("doe"), 0))
+	network := &bus{}
+	l1 := &slowL1{Storage: memory.New(), gate: make(chan struct{})}
+	a := newStore(t, l2, Config{L1: l1, Invalidator: network.invalidator()})
+	b := newStore(t, l2, Config{Invalidator: network.invalidator()})
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = a.Get("john")
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// The fill of L1 does not hold up the invalidation and the filled
+	// value is dropped
+	written := make(chan error, 1)
+	go func() {
+		written <- b.Set("john", []byte("smith"), 0)
+	}()
+	select {
+	case err := <-written:
+		utils.AssertEqual(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("invalidation blocked by the fill of L1")
+	}
+	close(l1.gate)
+	<-done
+
+	result, err := a.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("smith"), result)
+
+	utils.AssertEqual(t, nil, a.Close())
+	utils.AssertEqual(t, nil, b.L1().Close())
+}
+
+func Test_Tiered_WriteBehind(t *testing.T) {
+	l2 := &slowStorage{Storage: memory.New(), gate: make(chan struct{})}
+	utils.AssertEqual(t, nil, l2.Storage.Set("jane", []byte("doe"), 0))
+	l1 := memory.New()
+	store := newStore(t, l2, Config{L1: l1, Mode: WriteBehind})
+
+	// L2 is blocked, the writes are only in L1 and the queue
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, store.Delete("jane"))
+	result, err := store.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), result)
+
+	// Queued writes win over L2 when L1 lost the key
+	utils.AssertEqual(t, nil, l1.Reset())
+	result, err = store.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), result)
+	result, err = store.Get("jane")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, len(result) == 0)
+
+	close(l2.gate)
+	utils.AssertEqual(t, nil, store.Flush(context.Background()))
+	result, err = l2.Storage.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), result)
+	result, err = l2.Storage.Get("jane")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, len(result) == 0)
+
+	// Close writes the queue
+	utils.AssertEqual(t, nil, store.Set("joe", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, store.Close())
+	result, err = l2.Storage.Get("joe")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), result)
+	utils.AssertEqual(t, errClosed, store.Set("john", []byte("doe"), 0))
+}
+
+func Test_Tiered_Invalidation(t *testing.T) {
+	l2 := &slowStorage{Storage: memory.New()}
+	network := &bus{}
+	a := newStore(t, l2, Config{Invalidator: network.invalidator()})
+	b := newStore(t, l2, Config{Invalidator: network.invalidator()})
+
+	utils.AssertEqual(t, nil, a.Set("john", []byte("doe"), 0))
+	result, err := b.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("doe"), result)
+
+	// b drops its L1 copy when a writes
+	utils.AssertEqual(t, nil, a.Set("john", []byte("smith"), 0))
+	result, err = b.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, []byte("smith"), result)
+
+	utils.AssertEqual(t, nil, a.Delete("john"))
+	result, err = b.Get("john")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, len(result) == 0)
+
+	utils.AssertEqual(t, nil, b.Set("jane", []byte("doe"), 0))
+	_, _ = a.Get("jane")
+	utils.AssertEqual(t, nil, b.Reset())
+	result, err = a.Get("jane")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, len(result) == 0)
+
+	utils.AssertEqual(t, nil, a.Close())
+	// L2 is shared and already closed
+	utils.AssertEqual(t, nil, b.L1().Close())
+}
+
+func Test_Tiered_Shutdown(t *testing.T) {
+	l2 := &slowStorage{Storage: memory.New(), gate: make(chan struct{})}
+	var failed int32
+	store := newStore(t, l2, Config{
+		Mode:    WriteBehind,
+		OnError: func(string, error) { atomic.AddInt32(&failed, 1) },
+	})
+	utils.AssertEqual(t, nil, store.Set("john", []byte("doe"), 0))
+	utils.AssertEqual(t, nil, store.Set("jane", []byte("doe"), 0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	go func() {
+		// Release the write in progress once Shutdown gave up
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		close(l2.gate)
+	}()
+	err := store.Shutdown(ctx)
+	utils.AssertEqual(t, true, errors.Is(err, context.DeadlineExceeded))
+	utils.AssertEqual(t, err, store.Close())
+
+	// The write in progress finished, the one left was dropped
+	result, _ := l2.Storage.Get("john")
+	utils.AssertEqual(t, []byte("doe"), result)
+	result, _ = l2.Storage.Get("jane")
+	utils.AssertEqual(t, true, len(result) == 0)
+	utils.AssertEqual(t, int32(0), atomic.LoadInt32(&failed))
+}
+
+func Test_Tiered_Mode(t *testing.T) {
+	l2 := memory.New()
+	defer l2.Close()
+	_, err := New(l2, Config{Mode: "write-around"})
+	utils.AssertEqual(t, false, err == nil)
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Headers of the values written by Typed
+const (
+	typedRaw        byte = 0
+	typedCompressed byte = 1
+)
+
+// defaultCompressThreshold is used when TypedConfig.CompressThreshold is
+// not positive
+const defaultCompressThreshold = 1024
+
+// TypedConfig defines the config for Typed.
+type TypedConfig struct {
+	// Codec of the values
+	//
+	// Default is JSON
+	Codec Codec
+
+	// Compressor of the values of at least CompressThreshold bytes once
+	// encoded
+	//
+	// Optional. Default is nil, no compression
+	Compressor Compressor
+
+	// Minimum size in bytes of an encoded value to compress it
+	//
+	// Default is 1024
+	CompressThreshold int
+}
+
+// Typed stores values of type T in any Storage. Values are encoded with
+// the Codec and prefixed with a byte telling whether they are compressed,
+// so the threshold can change without breaking stored values.
+type Typed[T any] struct {
+	s          Storage
+	codec      Codec
+	compressor Compressor
+	threshold  int
+}
+
+// NewTyped returns a Typed storage of T values over s.
+func NewTyped[T any](s Storage, config ...TypedConfig) *Typed[T] {
+	var cfg TypedConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Codec == nil {
+		cfg.Codec = JSON
+	}
+	if cfg.CompressThreshold <= 0 {
+		cfg.CompressThreshold = defaultCompressThreshold
+	}
+	return &Typed[T]{
+		s:          s,
+		codec:      cfg.Codec,
+		compressor: cfg.Compressor,
+		threshold:  cfg.CompressThreshold,
+	}
+}
+
+// Get gets the value for the given key, ok is false when the key does not
+// exist.
+func (t *Typed[T]) Get(key string) (val T, ok bool, err error) {
+	data, err := t.s.Get(key)
+	if err != nil || len(data) == 0 {
+		return val, false, err
+	}
+
+	switch data[0] {
+	case typedRaw:
+		data = data[1:]
+	case typedCompressed:
+		if t.compressor == nil {
+			return val, false, fmt.Errorf("storage: value of %q is compressed but there is no Compressor", key)
+		}
+		if data, err = t.compressor.Decompress(data[1:]); err != nil {
+			return val, false, fmt.Errorf("storage: decompress value of %q: %w", key, err)
+		}
+	default:
+		return val, false, fmt.Errorf("storage: value of %q was not written by Typed", key)
+	}
+
+	if err = t.codec.Unmarshal(data, &val); err != nil {
+		return val, false, fmt.Errorf("storage: decode value of %q: %w", key, err)
+	}
+	return val, true, nil
+}
+
+// Set stores the given value for the given key along with an expiration
+// value, 0 means no expiration.
+func (t *Typed[T]) Set(key string, val T, exp time.Duration) error {
+	data, err := t.codec.Marshal(val)
+	if err != nil {
+		return fmt.Errorf("storage: encode value of %q: %w", key, err)
+	}
+
+	header := typedRaw
+	if t.compressor != nil && len(data) >= t.threshold {
+		if data, err = t.compressor.Compress(data); err != nil {
+			return fmt.Errorf("storage: compress value of %q: %w", key, err)
+		}
+		header = typedCompressed
+	}
+	return t.s.Set(key, append([]byte{header}, data...), exp)
+}
+
+// Delete deletes the value for the given key.
+func (t *Typed[T]) Delete(key string) error {
+	return t.s.Delete(key)
+}
+
+// Storage returns the underlying storage.
+func (t *Typed[T]) Storage() Storage {
+	return t.s
+}
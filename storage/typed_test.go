@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type user struct {
+	Name  string
+	Email string
+	Tags  []string
+}
+
+func TestTyped(t *testing.T) {
+	for name, codec := range map[string]Codec{"json": JSON, "gob": Gob} {
+		t.Run(name, func(t *testing.T) {
+			store := &slowStorage{db: map[string][]byte{}}
+			users := NewTyped[user](store, TypedConfig{Codec: codec})
+
+			_, ok, err := users.Get("john")
+			if err != nil || ok {
+				t.Fatalf("Expected %v, but got %v %v", false, ok, err)
+			}
+
+			expected := user{Name: "john", Email: "john@doe.com", Tags: []string{"admin"}}
+			if err = users.Set("john", expected, 0); err != nil {
+				t.Fatalf("Expected %v, but got %v", nil, err)
+			}
+			val, ok, err := users.Get("john")
+			if err != nil || !ok || !reflect.DeepEqual(val, expected) {
+				t.Errorf("Expected %v, but got %v %v %v", expected, val, ok, err)
+			}
+
+			if err = users.Delete("john"); err != nil {
+				t.Fatalf("Expected %v, but got %v", nil, err)
+			}
+			if _, ok, _ = users.Get("john"); ok {
+				t.Errorf("Expected %v, but got %v", false, ok)
+			}
+		})
+	}
+}
+
+func TestTyped_Compression(t *testing.T) {
+	store := &slowStorage{db: map[string][]byte{}}
+	texts := NewTyped[string](store, TypedConfig{
+		Compressor:        Gzip,
+		CompressThreshold: 64,
+	})
+
+	long := strings.Repeat("doe", 1000)
+	if err := texts.Set("long", long, 0); err != nil {
+		t.Fatalf("Expected %v, but got %v", nil, err)
+	}
+	if err := texts.Set("short", "doe", 0); err != nil {
+		t.Fatalf("Expected %v, but got %v", nil, err)
+	}
+
+	if store.db["long"][0] != typedCompressed || len(store.db["long"]) >= len(long) {
+		t.Errorf("Expected a compressed value, but got %d bytes", len(store.db["long"]))
+	}
+	if !bytes.Equal(store.db["short"], []byte("\x00\"doe\"")) {
+		t.Errorf("Expected %q, but got %q", "\x00\"doe\"", store.db["short"])
+	}
+
+	for key, expected := range map[string]string{"long": long, "short": "doe"} {
+		val, ok, err := texts.Get(key)
+		if err != nil || !ok || val != expected {
+			t.Errorf("Expected %v, but got %v %v", true, ok, err)
+		}
+	}
+
+	// Compressed values cannot be read without the Compressor
+	if _, _, err := NewTyped[string](store).Get("long"); err == nil {
+		t.Errorf("Expected an error, but got %v", err)
+	}
+	store.db["raw"] = []byte("doe")
+	if _, _, err := texts.Get("raw"); err == nil {
+		t.Errorf("Expected an error, but got %v", err)
+	}
+}